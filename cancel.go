@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// the classes an error of a query that was stopped falls into
+const (
+	errorClassNone             = "none"
+	errorClassCanceled         = "canceled"
+	errorClassDeadline         = "deadline exceeded"
+	errorClassStatementTimeout = "statement timeout"
+	errorClassOther            = "other"
+)
+
+// classifyQueryError tells whether err is from the context being canceled, its deadline passing, or postgres' own
+// statement_timeout
+func classifyQueryError(err error) string {
+	switch {
+	case err == nil:
+		return errorClassNone
+	case errors.Is(err, context.Canceled):
+		return errorClassCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return errorClassDeadline
+	}
+	// both pgconn versions' PgError, like readiness.Permanent checks
+	var state interface{ SQLState() string }
+	if errors.As(err, &state) && state.SQLState() == "57014" {
+		// query_canceled is used for both a cancel request and statement_timeout, only the message tells them apart
+		if strings.Contains(err.Error(), "statement timeout") {
+			return errorClassStatementTimeout
+		}
+		return errorClassCanceled
+	}
+	return errorClassOther
+}
+
+// cancelScenario is one way of stopping a query running longer than stopAfter, with the class of error every
+// library is expected to return
+type cancelScenario struct {
+	name     string
+	expected string
+	// run sleeps for sleep with library l, timeoutConns have statement_timeout set to stopAfter
+	run func(ctx context.Context, c, timeoutConns *connections, l libraryOps, sleep, stopAfter time.Duration) error
+}
+
+var cancelScenarios = []cancelScenario{
+	{
+		name:     "cancel",
+		expected: errorClassCanceled,
+		run: func(ctx context.Context, c, _ *connections, l libraryOps, sleep, stopAfter time.Duration) error {
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			timer := time.AfterFunc(stopAfter, cancel)
+			defer timer.Stop()
+			return l.sleep(ctx, c, sleep)
+		},
+	},
+	{
+		name:     "deadline",
+		expected: errorClassDeadline,
+		run: func(ctx context.Context, c, _ *connections, l libraryOps, sleep, stopAfter time.Duration) error {
+			ctx, cancel := context.WithTimeout(ctx, stopAfter)
+			defer cancel()
+			return l.sleep(ctx, c, sleep)
+		},
+	},
+	{
+		name:     "statement_timeout",
+		expected: errorClassStatementTimeout,
+		run: func(ctx context.Context, _, timeoutConns *connections, l libraryOps, sleep, _ time.Duration) error {
+			return l.sleep(ctx, timeoutConns, sleep)
+		},
+	},
+}
+
+// cancelResult is how one library handled one cancel scenario
+type cancelResult struct {
+	Library  string        `json:"library"`
+	Scenario string        `json:"scenario"`
+	Elapsed  time.Duration `json:"elapsed"`
+	Prompt   bool          `json:"prompt"`
+	Class    string        `json:"class"`
+	Expected string        `json:"expected"`
+	Error    string        `json:"error,omitempty"`
+	// Healthy is whether a query on the same pool afterwards succeeded and left no connection in use
+	Healthy     bool   `json:"healthy"`
+	HealthError string `json:"healthError,omitempty"`
+}
+
+func (r cancelResult) ok() bool {
+	return r.Prompt && r.Class == r.Expected && r.Healthy
+}
+
+// runCancel stops a pg_sleep with every library by canceling its context, by its context's deadline and by
+// statement_timeout, and checks each returns promptly with an error classified as expected and leaves its pool
+// healthy. It returns 1 if any did not.
+func runCancel(ctx context.Context, args []string) int {
+	flags := flag.NewFlagSet("cancel", flag.ExitOnError)
+	dsn := flags.String("dsn", connectionString, "connection string")
+	sleep := flags.Duration("sleep", 5*time.Second, "how long the query sleeps if nothing stops it")
+	stopAfter := flags.Duration("after", 200*time.Millisecond, "when the context is canceled, its deadline, and the statement_timeout")
+	grace := flags.Duration("grace", time.Second, "how much longer than -after a library may take to return and still be prompt")
+	format := flags.String("format", "table", "output format: table or json")
+	_ = flags.Parse(args)
+
+	conns, err := openConnections(ctx, *dsn)
+	if err != nil {
+		log.Fatalf("unable to connect: %v", err)
+	}
+	defer conns.Close()
+
+	timeoutDSN, err := withParameter(*dsn, "statement_timeout", strconv.FormatInt(stopAfter.Milliseconds(), 10))
+	if err != nil {
+		log.Fatalf("invalid connection string: %v", err)
+	}
+	timeoutConns, err := openConnections(ctx, timeoutDSN)
+	if err != nil {
+		log.Fatalf("unable to connect with statement_timeout: %v", err)
+	}
+	defer timeoutConns.Close()
+
+	results := make([]cancelResult, 0, len(libraries)*len(cancelScenarios))
+	for _, l := range libraries {
+		for _, s := range cancelScenarios {
+			r := cancelResult{Library: l.library, Scenario: s.name, Expected: s.expected}
+			start := time.Now()
+			err := s.run(ctx, conns, timeoutConns, l, *sleep, *stopAfter)
+			r.Elapsed = time.Since(start)
+			r.Prompt = r.Elapsed <= *stopAfter+*grace
+			r.Class = classifyQueryError(err)
+			if err != nil {
+				r.Error = err.Error()
+			}
+
+			checked := conns
+			if s.name == "statement_timeout" {
+				checked = timeoutConns
+			}
+			if err := checkPoolHealth(ctx, checked, l); err != nil {
+				r.HealthError = err.Error()
+			} else {
+				r.Healthy = true
+			}
+			results = append(results, r)
+		}
+	}
+
+	switch *format {
+	case "json":
+		b, _ := json.MarshalIndent(results, "", "  ")
+		fmt.Println(string(b))
+	case "table":
+		printCancelResults(results)
+	default:
+		log.Fatalf("unknown format %q", *format)
+	}
+
+	for _, r := range results {
+		if !r.ok() {
+			return 1
+		}
+	}
+	return 0
+}
+
+// checkPoolHealth runs a query with library l and checks it left no connection of its pool in use
+func checkPoolHealth(ctx context.Context, c *connections, l libraryOps) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := l.sleep(ctx, c, 0); err != nil {
+		return err
+	}
+	if inUse := libraryPoolStats(c, l.library).InUse; inUse > 0 {
+		return fmt.Errorf("%d connections still in use", inUse)
+	}
+	return nil
+}
+
+func printCancelResults(results []cancelResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "LIBRARY\tSCENARIO\tELAPSED\tPROMPT\tERROR CLASS\tEXPECTED\tHEALTHY")
+	for _, r := range results {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%s\t%s\t%t\n",
+			r.Library, r.Scenario, r.Elapsed.Round(time.Millisecond), r.Prompt, r.Class, r.Expected, r.Healthy)
+	}
+	_ = w.Flush()
+
+	for _, r := range results {
+		if r.ok() {
+			continue
+		}
+		fmt.Printf("\n%s %s:\n", r.Library, r.Scenario)
+		if r.Error != "" {
+			fmt.Printf("  error: %s\n", r.Error)
+		}
+		if r.HealthError != "" {
+			fmt.Printf("  afterwards: %s\n", r.HealthError)
+		}
+	}
+}
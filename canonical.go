@@ -0,0 +1,196 @@
+package main
+
+import (
+	"database/sql"
+	"github.com/jackc/pgx/v5/pgtype"
+	mysqlsqlb "go-orm-test/mysql/sqlbdb"
+	mysqlsqlc "go-orm-test/mysql/sqlcdb"
+	"go-orm-test/sqlbdb"
+	"go-orm-test/sqlcdb"
+	sqlitesqlb "go-orm-test/sqlite/sqlbdb"
+	sqlitesqlc "go-orm-test/sqlite/sqlcdb"
+	"time"
+)
+
+// Sample is the canonical shape every library's model is mapped to, so output from different libraries can be
+// printed and compared the same way. Times are always in UTC.
+type Sample struct {
+	ID          int        `json:"id"`
+	Name        string     `json:"name"`
+	Description *string    `json:"description"`
+	IntExample  *int       `json:"intExample"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+	DeletedAt   *time.Time `json:"deletedAt"`
+	Slug        *string    `json:"slug"`
+}
+
+func sampleFromCustom(c CustomSample) Sample {
+	return Sample{
+		ID:          c.ID,
+		Name:        c.Name,
+		Description: c.Description,
+		IntExample:  c.IntExample,
+		CreatedAt:   c.CreatedAt.UTC(),
+		UpdatedAt:   c.UpdatedAt.UTC(),
+		DeletedAt:   utcPtr(c.DeletedAt),
+		Slug:        c.Slug,
+	}
+}
+
+func sampleFromSqlx(s SqlxSample) Sample {
+	return Sample{
+		ID:          s.ID,
+		Name:        s.Name,
+		Description: s.Description,
+		IntExample:  s.IntExample,
+		CreatedAt:   s.CreatedAt.UTC(),
+		UpdatedAt:   s.UpdatedAt.UTC(),
+		DeletedAt:   utcPtr(s.DeletedAt),
+		Slug:        s.Slug,
+	}
+}
+
+func sampleFromGorm(s SampleTable) Sample {
+	return Sample{
+		ID:          s.ID,
+		Name:        s.Name,
+		Description: s.Description,
+		IntExample:  s.IntExample,
+		CreatedAt:   s.CreatedAt.UTC(),
+		UpdatedAt:   s.UpdatedAt.UTC(),
+		DeletedAt:   utcPtr(s.DeletedAt),
+		Slug:        s.Slug,
+	}
+}
+
+func sampleFromSqlc(s sqlcdb.TestSampleTable) Sample {
+	var intExample *int
+	if s.IntExample != nil {
+		intExample = ptr(int(*s.IntExample))
+	}
+	return Sample{
+		ID:          int(s.ID),
+		Name:        s.Name,
+		Description: s.Description,
+		IntExample:  intExample,
+		CreatedAt:   s.CreatedAt.Time.UTC(),
+		UpdatedAt:   s.UpdatedAt.Time.UTC(),
+		DeletedAt:   timestamptzPtr(s.DeletedAt),
+		Slug:        s.Slug,
+	}
+}
+
+func sampleFromSqlboiler(s *sqlbdb.SampleTable) Sample {
+	return Sample{
+		ID:          s.ID,
+		Name:        s.Name,
+		Description: s.Description.Ptr(),
+		IntExample:  s.IntExample.Ptr(),
+		CreatedAt:   s.CreatedAt.UTC(),
+		UpdatedAt:   s.UpdatedAt.UTC(),
+		DeletedAt:   utcPtr(s.DeletedAt.Ptr()),
+		Slug:        s.Slug.Ptr(),
+	}
+}
+
+func sampleFromSQLiteSqlc(s sqlitesqlc.SampleTable) Sample {
+	var intExample *int
+	if s.IntExample.Valid {
+		intExample = ptr(int(s.IntExample.Int64))
+	}
+	return Sample{
+		ID:          int(s.ID),
+		Name:        s.Name,
+		Description: nullStringPtr(s.Description),
+		IntExample:  intExample,
+		CreatedAt:   s.CreatedAt.UTC(),
+		UpdatedAt:   s.UpdatedAt.UTC(),
+		DeletedAt:   nullTimePtr(s.DeletedAt),
+		Slug:        nullStringPtr(s.Slug),
+	}
+}
+
+func sampleFromSQLiteSqlboiler(s *sqlitesqlb.SampleTable) Sample {
+	var intExample *int
+	if s.IntExample.Valid {
+		intExample = ptr(int(s.IntExample.Int64))
+	}
+	return Sample{
+		ID:          int(s.ID),
+		Name:        s.Name,
+		Description: s.Description.Ptr(),
+		IntExample:  intExample,
+		CreatedAt:   s.CreatedAt.UTC(),
+		UpdatedAt:   s.UpdatedAt.UTC(),
+		DeletedAt:   utcPtr(s.DeletedAt.Ptr()),
+		Slug:        s.Slug.Ptr(),
+	}
+}
+
+// mapSamples converts a slice of any library's model to canonical samples
+func mapSamples[T any](in []T, mapper func(T) Sample) []Sample {
+	out := make([]Sample, 0, len(in))
+	for _, t := range in {
+		out = append(out, mapper(t))
+	}
+	return out
+}
+
+func utcPtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	return ptr(t.UTC())
+}
+
+func timestamptzPtr(t pgtype.Timestamptz) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return ptr(t.Time.UTC())
+}
+
+func nullStringPtr(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return ptr(t.Time.UTC())
+}
+
+func sampleFromMySQLSqlc(s mysqlsqlc.SampleTable) Sample {
+	var intExample *int
+	if s.IntExample.Valid {
+		intExample = ptr(int(s.IntExample.Int32))
+	}
+	return Sample{
+		ID:          int(s.ID),
+		Name:        s.Name,
+		Description: nullStringPtr(s.Description),
+		IntExample:  intExample,
+		CreatedAt:   s.CreatedAt.UTC(),
+		UpdatedAt:   s.UpdatedAt.UTC(),
+		DeletedAt:   nullTimePtr(s.DeletedAt),
+		Slug:        nullStringPtr(s.Slug),
+	}
+}
+
+func sampleFromMySQLSqlboiler(s *mysqlsqlb.SampleTable) Sample {
+	return Sample{
+		ID:          s.ID,
+		Name:        s.Name,
+		Description: s.Description.Ptr(),
+		IntExample:  s.IntExample.Ptr(),
+		CreatedAt:   s.CreatedAt.UTC(),
+		UpdatedAt:   s.UpdatedAt.UTC(),
+		DeletedAt:   utcPtr(s.DeletedAt.Ptr()),
+		Slug:        s.Slug.Ptr(),
+	}
+}
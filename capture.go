@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// capturedStatement is one statement exactly as a library sent it to the database
+type capturedStatement struct {
+	Library   string        `json:"library"`
+	Operation string        `json:"operation"`
+	SQL       string        `json:"sql"`
+	Args      []any         `json:"args"`
+	Duration  time.Duration `json:"duration"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// statementCapture collects the statements sent by every library. Only statements whose context was labeled with
+// withOperation are recorded, so migrations and other setup are left out.
+type statementCapture struct {
+	mu         sync.Mutex
+	statements []capturedStatement
+}
+
+func (c *statementCapture) record(ctx context.Context, library, query string, args []any, start time.Time, err error) {
+	operation, ok := operationFrom(ctx)
+	if !ok {
+		return
+	}
+	s := capturedStatement{
+		Library:   library,
+		Operation: operation,
+		SQL:       query,
+		Args:      args,
+		Duration:  time.Since(start),
+	}
+	if err != nil {
+		s.Error = err.Error()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.statements = append(c.statements, s)
+}
+
+// Statements returns a copy of everything recorded so far
+func (c *statementCapture) Statements() []capturedStatement {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]capturedStatement(nil), c.statements...)
+}
+
+type operationKey struct{}
+
+// withOperation labels every statement run with ctx as part of the named operation
+func withOperation(ctx context.Context, operation string) context.Context {
+	return context.WithValue(ctx, operationKey{}, operation)
+}
+
+func operationFrom(ctx context.Context) (string, bool) {
+	operation, ok := ctx.Value(operationKey{}).(string)
+	return operation, ok
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// database/sql driver wrapper, used by custom, sqlx, gorm and sqlboiler
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// openCapturingDB opens a pool that records every statement in capture as sent by library.
+// gorm goes through this too instead of its logger, since the logger only sees the sql with the args interpolated.
+func openCapturingDB(library, connectionString string, capture *statementCapture) (*sql.DB, error) {
+	base, err := sql.Open(driverName, connectionString)
+	if err != nil {
+		return nil, err
+	}
+	d := base.Driver()
+	_ = base.Close()
+
+	return sql.OpenDB(capturingConnector{
+		driver:           d,
+		connectionString: connectionString,
+		library:          library,
+		capture:          capture,
+	}), nil
+}
+
+type capturingConnector struct {
+	driver           driver.Driver
+	connectionString string
+	library          string
+	capture          *statementCapture
+}
+
+func (c capturingConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(c.connectionString)
+	if err != nil {
+		return nil, err
+	}
+	return &capturingConn{Conn: conn, library: c.library, capture: c.capture}, nil
+}
+
+func (c capturingConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// capturingConn forwards everything to the wrapped connection, recording queries and execs on the way
+type capturingConn struct {
+	driver.Conn
+	library string
+	capture *statementCapture
+}
+
+// QueryContext records the time until the driver returns the rows, not the time to read all of them
+func (c *capturingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != driver.ErrSkip {
+		c.capture.record(ctx, c.library, query, namedValues(args), start, err)
+	}
+	return rows, err
+}
+
+func (c *capturingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	result, err := execer.ExecContext(ctx, query, args)
+	if err != driver.ErrSkip {
+		c.capture.record(ctx, c.library, query, namedValues(args), start, err)
+	}
+	return result, err
+}
+
+func (c *capturingConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *capturingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin() // fallback for drivers without BeginTx
+}
+
+func (c *capturingConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// CheckNamedValue must be forwarded, pgx accepts argument types that database/sql would otherwise reject
+func (c *capturingConn) CheckNamedValue(v *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(v)
+	}
+	return driver.ErrSkip
+}
+
+func (c *capturingConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func namedValues(args []driver.NamedValue) []any {
+	values := make([]any, 0, len(args))
+	for _, a := range args {
+		values = append(values, a.Value)
+	}
+	return values
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// pgx tracer, used by sqlc
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+type captureStartKey struct{}
+
+type captureStartData struct {
+	start time.Time
+	data  pgx.TraceQueryStartData
+}
+
+// captureTracer is a pgx.QueryTracer recording every statement in capture as sent by library
+type captureTracer struct {
+	library string
+	capture *statementCapture
+}
+
+func (t captureTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, captureStartKey{}, captureStartData{start: time.Now(), data: data})
+}
+
+func (t captureTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	start, ok := ctx.Value(captureStartKey{}).(captureStartData)
+	if !ok {
+		return
+	}
+	t.capture.record(ctx, t.library, start.data.SQL, start.data.Args, start.start, data.Err)
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// capture command
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// captureScenario is run once per library, state is shared between the scenarios of one library
+type captureScenario struct {
+	name string
+	run  func(ctx context.Context, c *connections, l libraryOps, state *captureState) error
+}
+
+type captureState struct {
+	id int
+}
+
+var captureScenarios = []captureScenario{
+	{"insert", func(ctx context.Context, c *connections, l libraryOps, _ *captureState) error {
+		return l.insert(ctx, c, Sample{Name: l.library + " captured sample", Description: ptr("captured")})
+	}},
+	{"insert returning", func(ctx context.Context, c *connections, l libraryOps, state *captureState) error {
+		s, err := l.insertReturning(ctx, c, Sample{Name: l.library + " captured sample", IntExample: ptr(1)})
+		state.id = s.ID
+		return err
+	}},
+	{"get by id", func(ctx context.Context, c *connections, l libraryOps, state *captureState) error {
+		_, err := l.getByID(ctx, c, state.id)
+		return err
+	}},
+	{"list all", func(ctx context.Context, c *connections, l libraryOps, _ *captureState) error {
+		_, err := l.listAll(ctx, c)
+		return err
+	}},
+}
+
+// runCapture runs every scenario with every library and prints the statements each library sent
+func runCapture(ctx context.Context, args []string) int {
+	flags := flag.NewFlagSet("capture", flag.ExitOnError)
+	dsn := flags.String("dsn", connectionString, "connection string")
+	format := flags.String("format", "table", "output format: table or json")
+	explain := flags.Bool("explain", false, "also run EXPLAIN ANALYZE for every captured statement")
+	_ = flags.Parse(args)
+
+	capture := &statementCapture{}
+	conns, err := openConnections(ctx, *dsn, withCapture(capture))
+	if err != nil {
+		log.Fatalf("unable to connect: %v", err)
+	}
+	defer conns.Close()
+	migrateWithGoose(conns.custom)
+
+	exitCode := 0
+	for _, l := range libraries {
+		state := &captureState{}
+		for _, s := range captureScenarios {
+			if err := s.run(withOperation(ctx, s.name), conns, l, state); err != nil {
+				log.Printf("%s %s: %v", l.library, s.name, err)
+				exitCode = 1
+			}
+		}
+	}
+
+	var plans []capturedPlan
+	if *explain {
+		plans = explainStatements(ctx, conns.custom, capture.Statements())
+	}
+
+	switch *format {
+	case "json":
+		b, _ := json.MarshalIndent(struct {
+			Statements []capturedStatement `json:"statements"`
+			Plans      []capturedPlan      `json:"plans,omitempty"`
+		}{capture.Statements(), plans}, "", "  ")
+		fmt.Println(string(b))
+	case "table":
+		printCapturedStatements(capture.Statements())
+		if *explain {
+			printPlanSummary(plans)
+		}
+	default:
+		log.Fatalf("unknown format %q", *format)
+	}
+	return exitCode
+}
+
+// printCapturedStatements prints a table per scenario with the statements of every library next to each other
+func printCapturedStatements(statements []capturedStatement) {
+	for _, s := range captureScenarios {
+		fmt.Printf("== %s ==\n", s.name)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "LIBRARY\tDURATION\tSQL\tARGS")
+		for _, st := range statements {
+			if st.Operation != s.name {
+				continue
+			}
+			args, _ := json.Marshal(st.Args)
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s", st.Library, st.Duration.Round(time.Microsecond), strings.Join(strings.Fields(st.SQL), " "), args)
+			if st.Error != "" {
+				_, _ = fmt.Fprintf(w, "\terror: %s", st.Error)
+			}
+			_, _ = fmt.Fprintln(w)
+		}
+		_ = w.Flush()
+		fmt.Println()
+	}
+}
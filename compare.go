@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// listAllSamples lists the table with each library, sorting each result by id so they can be compared
+func listAllSamples(ctx context.Context, c *connections, libraries []libraryOps) (map[string][]Sample, error) {
+	results := make(map[string][]Sample, len(libraries))
+	for _, l := range libraries {
+		samples, err := l.listAll(ctx, c)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", l.library, err)
+		}
+		sort.Slice(samples, func(i, j int) bool { return samples[i].ID < samples[j].ID })
+		results[l.library] = samples
+	}
+	return results, nil
+}
+
+// runCompare lists the table with every library and reports where their canonical results differ, either as a side by
+// side table of mismatched fields, as json, or as a line diff of the canonical json against the custom (plain
+// database/sql) result. It returns 1 if any library differs.
+func runCompare(ctx context.Context, args []string) int {
+	flags := flag.NewFlagSet("compare", flag.ExitOnError)
+	dsn := flags.String("dsn", connectionString, "connection string")
+	format := flags.String("format", "table", "output format: table, json or lines")
+	precision := flags.Duration("precision", time.Microsecond, "timestamps are truncated to this precision before comparing")
+	color := flags.Bool("color", isTerminal(os.Stdout), "color the table output")
+	_ = flags.Parse(args)
+
+	conns, err := openConnections(ctx, *dsn)
+	if err != nil {
+		log.Fatalf("unable to connect: %v", err)
+	}
+	defer conns.Close()
+	migrateWithGoose(conns.custom)
+
+	results, err := listAllSamples(ctx, conns, libraries)
+	if err != nil {
+		log.Fatalf("unable to list samples: %v", err)
+	}
+
+	names := libraryNames(libraries)
+
+	switch *format {
+	case "lines":
+		return printLineDiffs(results, names)
+	case "table", "json":
+		mismatches := diffSamples(results, names, *precision)
+		if *format == "json" {
+			printMismatchJSON(os.Stdout, mismatches)
+		} else if len(mismatches) == 0 {
+			fmt.Println("every library returned the same rows")
+		} else {
+			printMismatchTable(os.Stdout, mismatches, names, *color)
+		}
+		if len(mismatches) > 0 {
+			return 1
+		}
+		return 0
+	default:
+		log.Fatalf("unknown format %q", *format)
+		return 2
+	}
+}
+
+// printLineDiffs diffs the canonical json of each library against the first, returning 1 if any differ
+func printLineDiffs(results map[string][]Sample, libraries []string) int {
+	baseline := libraries[0]
+	expected := canonicalJSONLines(results[baseline])
+	exitCode := 0
+	for _, lib := range libraries[1:] {
+		diff := diffLines(expected, canonicalJSONLines(results[lib]))
+		if len(diff) == 0 {
+			fmt.Printf("%s: same as %s\n", lib, baseline)
+			continue
+		}
+		exitCode = 1
+		fmt.Printf("%s: differs from %s\n--- %s\n+++ %s\n", lib, baseline, baseline, lib)
+		for _, line := range diff {
+			fmt.Println(line)
+		}
+	}
+	return exitCode
+}
+
+func canonicalJSONLines(samples []Sample) []string {
+	b, _ := json.MarshalIndent(samples, "", "  ")
+	return strings.Split(string(b), "\n")
+}
+
+// diffLines returns a minimal line diff of a and b, with removed lines prefixed by "-" and added lines by "+".
+// Nothing is returned when they are equal.
+func diffLines(a, b []string) []string {
+	// longest common subsequence table, lcs[i][j] is the lcs length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	diff := make([]string, 0)
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, "-"+a[i])
+			i++
+		default:
+			diff = append(diff, "+"+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		diff = append(diff, "-"+a[i])
+	}
+	for ; j < len(b); j++ {
+		diff = append(diff, "+"+b[j])
+	}
+	return diff
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jmoiron/sqlx"
+	"go-orm-test/readiness"
+	"go-orm-test/sqlcdb"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+	"log"
+	"time"
+	//_ "github.com/lib/pq"
+)
+
+// connections holds one handle per access style so the same scenario can be run with each of them
+type connections struct {
+	custom      *sql.DB
+	sqlx        *sqlx.DB
+	gorm        *gorm.DB
+	sqlc        *pgxpool.Pool
+	sqlcQueries *sqlcdb.Queries
+	sqlboiler   *sql.DB
+}
+
+// connectionOptions are set with the connectionOption functions passed to openConnections
+type connectionOptions struct {
+	capture   *statementCapture
+	readiness readiness.Config
+	pool      poolConfig
+}
+
+type connectionOption func(o *connectionOptions)
+
+// withCapture records every statement sent by any library in capture
+func withCapture(capture *statementCapture) connectionOption {
+	return func(o *connectionOptions) {
+		o.capture = capture
+	}
+}
+
+// withReadiness replaces how long and how often every library retries connecting while the database starts up
+func withReadiness(cfg readiness.Config) connectionOption {
+	return func(o *connectionOptions) {
+		o.readiness = cfg
+	}
+}
+
+// withPool replaces the pool settings read from the pool config
+func withPool(cfg poolConfig) connectionOption {
+	return func(o *connectionOptions) {
+		o.pool = cfg
+	}
+}
+
+// waitingForDB is readiness.Default logging every retry
+var waitingForDB = readiness.Config{
+	Initial: readiness.Default.Initial,
+	Max:     readiness.Default.Max,
+	Jitter:  readiness.Default.Jitter,
+	Timeout: readiness.Default.Timeout,
+	OnRetry: func(attempt int, wait time.Duration, err error) {
+		log.Printf("Waiting %s for db to start (attempt %d): %v", wait.Round(time.Millisecond), attempt, err)
+	},
+}
+
+// openConnections connects every library to the database described by connectionString, waiting for it to accept
+// connections first
+func openConnections(ctx context.Context, connectionString string, opts ...connectionOption) (*connections, error) {
+	o := connectionOptions{readiness: waitingForDB, pool: pools}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	// every database/sql based library gets its own pool, so captured statements can be attributed to it
+	openDB := func(library string) (*sql.DB, error) {
+		var db *sql.DB
+		var err error
+		if o.capture == nil {
+			db, err = sql.Open(driverName, connectionString)
+		} else {
+			db, err = openCapturingDB(library, connectionString, o.capture)
+		}
+		if err != nil {
+			return nil, err
+		}
+		o.pool.SQL.apply(db)
+		return db, nil
+	}
+
+	c := &connections{}
+	var err error
+
+	// custom connection
+	c.custom, err = openDB("custom")
+	if err != nil {
+		return nil, err
+	}
+	if err = readiness.Wait(ctx, o.readiness, c.custom.PingContext); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	// sqlx connection
+	sqlxDB, err := openDB("sqlx")
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	c.sqlx = sqlx.NewDb(sqlxDB, driverName)
+	if err = readiness.Wait(ctx, o.readiness, c.sqlx.PingContext); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	// gorm connection
+	gormDB, err := openDB("gorm")
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	if err = readiness.Wait(ctx, o.readiness, gormDB.PingContext); err != nil {
+		safeClose(gormDB)
+		c.Close()
+		return nil, err
+	}
+	c.gorm, err = gorm.Open(postgres.New(postgres.Config{Conn: gormDB}), &gorm.Config{
+		NamingStrategy: schema.NamingStrategy{
+			TablePrefix:   "test.",
+			SingularTable: true,
+		},
+	})
+	if err != nil {
+		safeClose(gormDB)
+		c.Close()
+		return nil, err
+	}
+
+	// sqlc connection, a pool since a single pgx.Conn can't be used concurrently
+	sqlcConfig, err := pgxpool.ParseConfig(connectionString)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	o.pool.Pgxpool.apply(sqlcConfig)
+	if o.capture != nil {
+		sqlcConfig.ConnConfig.Tracer = captureTracer{library: "sqlc", capture: o.capture}
+	}
+	c.sqlc, err = pgxpool.NewWithConfig(ctx, sqlcConfig)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	if err = readiness.Wait(ctx, o.readiness, c.sqlc.Ping); err != nil {
+		c.Close()
+		return nil, err
+	}
+	c.sqlcQueries = sqlcdb.New(c.sqlc)
+
+	// sqlboiler connection
+	c.sqlboiler, err = openDB("sqlboiler")
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	if err = readiness.Wait(ctx, o.readiness, c.sqlboiler.PingContext); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Close closes every connection that was opened, ignoring errors
+func (c *connections) Close() {
+	if c.custom != nil {
+		safeClose(c.custom)
+	}
+	if c.sqlx != nil {
+		safeClose(c.sqlx)
+	}
+	if c.gorm != nil {
+		if db, err := c.gorm.DB(); err == nil {
+			safeClose(db)
+		}
+	}
+	if c.sqlc != nil {
+		c.sqlc.Close()
+	}
+	if c.sqlboiler != nil {
+		safeClose(c.sqlboiler)
+	}
+}
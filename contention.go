@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go-orm-test/txretry"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+)
+
+// contentionResult is how one library's concurrent increments of one row ended up, with or without retrying
+type contentionResult struct {
+	Library     string `json:"library"`
+	MaxAttempts int    `json:"maxAttempts"`
+	Expected    int    `json:"expected"`
+	Final       int    `json:"final"`
+	Converged   bool   `json:"converged"`
+	Committed   int64  `json:"committed"`
+	Failed      int64  `json:"failed"`
+	// SerializationFailures and Deadlocks count the retries by their cause
+	SerializationFailures int64         `json:"serializationFailures"`
+	Deadlocks             int64         `json:"deadlocks"`
+	Elapsed               time.Duration `json:"elapsed"`
+	FirstError            string        `json:"firstError,omitempty"`
+}
+
+// runContention has many goroutines increment the same row in serializable transactions with every library, once
+// without retrying and once retrying serialization failures and deadlocks, and checks that with retries every
+// increment lands. It returns 1 if any library's retried increments didn't converge.
+func runContention(ctx context.Context, args []string) int {
+	flags := flag.NewFlagSet("contention", flag.ExitOnError)
+	dsn := flags.String("dsn", connectionString, "connection string")
+	workers := flags.Int("concurrency", 8, "goroutines incrementing the row per library")
+	increments := flags.Int("increments", 20, "increments per goroutine")
+	policy := txretry.Default
+	flags.IntVar(&policy.MaxAttempts, "max-attempts", 50, "most times a transaction is run, more than txretry.Default as every goroutine contends on the same row")
+	flags.DurationVar(&policy.Initial, "backoff", policy.Initial, "first wait before retrying, doubled after every failure")
+	flags.DurationVar(&policy.Max, "max-backoff", policy.Max, "longest wait before retrying")
+	flags.Float64Var(&policy.Jitter, "jitter", policy.Jitter, "fraction every wait is randomized by")
+	format := flags.String("format", "table", "output format: table or json")
+	_ = flags.Parse(args)
+
+	conns, err := openConnections(ctx, *dsn)
+	if err != nil {
+		log.Fatalf("unable to connect: %v", err)
+	}
+	defer conns.Close()
+	migrateWithGoose(conns.custom)
+
+	noRetry := policy
+	noRetry.MaxAttempts = 1
+
+	results := make([]contentionResult, 0, 2*len(libraries))
+	for _, l := range libraries {
+		for _, p := range []txretry.Policy{noRetry, policy} {
+			r, err := runContentionScenario(ctx, conns, l, p, *workers, *increments)
+			if err != nil {
+				log.Fatalf("%s: %v", l.library, err)
+			}
+			results = append(results, r)
+		}
+	}
+
+	switch *format {
+	case "json":
+		b, _ := json.MarshalIndent(results, "", "  ")
+		fmt.Println(string(b))
+	case "table":
+		printContentionResults(results)
+	default:
+		log.Fatalf("unknown format %q", *format)
+	}
+
+	for _, r := range results {
+		if r.MaxAttempts > 1 && !r.Converged {
+			return 1
+		}
+	}
+	return 0
+}
+
+// runContentionScenario inserts a row with int_example 0 and has workers goroutines increment it increments times
+// each with library l, retrying with p
+func runContentionScenario(ctx context.Context, c *connections, l libraryOps, p txretry.Policy, workers, increments int) (contentionResult, error) {
+	r := contentionResult{Library: l.library, MaxAttempts: p.MaxAttempts, Expected: workers * increments}
+	row, err := l.insertReturning(ctx, c, Sample{Name: l.library + " contention", IntExample: ptr(0)})
+	if err != nil {
+		return r, err
+	}
+
+	var committed, failed, serialization, deadlocks atomic.Int64
+	p.OnRetry = func(_ int, _ time.Duration, err error) {
+		var pgErr interface{ SQLState() string }
+		if errors.As(err, &pgErr) && pgErr.SQLState() == "40P01" {
+			deadlocks.Add(1)
+		} else {
+			serialization.Add(1)
+		}
+	}
+	var firstErr sync.Once
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < increments; i++ {
+				if err := l.increment(ctx, c, row.ID, p); err != nil {
+					failed.Add(1)
+					firstErr.Do(func() { r.FirstError = err.Error() })
+					continue
+				}
+				committed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	r.Elapsed = time.Since(start)
+
+	final, err := l.getByID(ctx, c, row.ID)
+	if err != nil {
+		return r, err
+	}
+	if final.IntExample != nil {
+		r.Final = *final.IntExample
+	}
+	r.Committed, r.Failed = committed.Load(), failed.Load()
+	r.SerializationFailures, r.Deadlocks = serialization.Load(), deadlocks.Load()
+	r.Converged = r.Final == r.Expected && r.Failed == 0
+	return r, nil
+}
+
+func printContentionResults(results []contentionResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "LIBRARY\tMAX ATTEMPTS\tEXPECTED\tFINAL\tCOMMITTED\tFAILED\tRETRIED 40001\tRETRIED 40P01\tELAPSED\tCONVERGED")
+	for _, r := range results {
+		_, _ = fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%s\t%t\n",
+			r.Library, r.MaxAttempts, r.Expected, r.Final, r.Committed, r.Failed, r.SerializationFailures, r.Deadlocks,
+			r.Elapsed.Round(time.Millisecond), r.Converged)
+	}
+	_ = w.Flush()
+
+	for _, r := range results {
+		if r.FirstError != "" {
+			fmt.Printf("%s with %d attempts: %s\n", r.Library, r.MaxAttempts, r.FirstError)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+	"go-orm-test/txretry"
+	"testing"
+)
+
+// TestContentionConverges has a few goroutines increment the same row with every library, retrying with
+// txretry.Default, and checks every increment landed
+func TestContentionConverges(t *testing.T) {
+	for _, l := range libraries {
+		l := l
+		t.Run(l.library, func(t *testing.T) {
+			t.Parallel()
+			r, err := runContentionScenario(context.Background(), newTestConnections(t), l, txretry.Default, 4, 5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !r.Converged {
+				t.Errorf("int_example is %d after %d increments, %d failed: %s", r.Final, r.Expected, r.Failed, r.FirstError)
+			}
+		})
+	}
+}
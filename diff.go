@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	colorRed   = "\033[31m"
+	colorGreen = "\033[32m"
+	colorPlain = "\033[39m"
+	colorReset = "\033[0m"
+
+	// missingValue is shown for a library that did not return a row at all
+	missingValue = "<missing>"
+)
+
+// sampleMismatch is one field of one row that was not the same for every library
+type sampleMismatch struct {
+	ID     int               `json:"id"`
+	Field  string            `json:"field"`
+	Values map[string]string `json:"values"` // keyed by library
+}
+
+// sampleField formats one field of a sample for comparison, nil values are formatted as "null"
+type sampleField struct {
+	name   string
+	format func(s Sample, precision time.Duration) string
+}
+
+var sampleFields = []sampleField{
+	{"name", func(s Sample, _ time.Duration) string { return strconv.Quote(s.Name) }},
+	{"description", func(s Sample, _ time.Duration) string {
+		if s.Description == nil {
+			return "null"
+		}
+		return strconv.Quote(*s.Description)
+	}},
+	{"intExample", func(s Sample, _ time.Duration) string {
+		if s.IntExample == nil {
+			return "null"
+		}
+		return strconv.Itoa(*s.IntExample)
+	}},
+	{"createdAt", func(s Sample, precision time.Duration) string { return formatDiffTime(&s.CreatedAt, precision) }},
+	{"updatedAt", func(s Sample, precision time.Duration) string { return formatDiffTime(&s.UpdatedAt, precision) }},
+	{"deletedAt", func(s Sample, precision time.Duration) string { return formatDiffTime(s.DeletedAt, precision) }},
+	{"slug", func(s Sample, _ time.Duration) string {
+		if s.Slug == nil {
+			return "null"
+		}
+		return strconv.Quote(*s.Slug)
+	}},
+}
+
+// formatDiffTime truncates to the given precision so libraries that keep more (or less) of the fraction than postgres
+// stores are not reported as different
+func formatDiffTime(t *time.Time, precision time.Duration) string {
+	if t == nil {
+		return "null"
+	}
+	return t.UTC().Truncate(precision).Format(time.RFC3339Nano)
+}
+
+// diffSamples aligns the results of every library by id and returns each field that is not the same for all of them.
+// A row missing from some libraries is reported once with the field "row".
+func diffSamples(results map[string][]Sample, libraries []string, precision time.Duration) []sampleMismatch {
+	byID := make(map[string]map[int]Sample, len(libraries))
+	ids := make([]int, 0)
+	seen := map[int]bool{}
+	for _, lib := range libraries {
+		byID[lib] = make(map[int]Sample, len(results[lib]))
+		for _, s := range results[lib] {
+			byID[lib][s.ID] = s
+			if !seen[s.ID] {
+				seen[s.ID] = true
+				ids = append(ids, s.ID)
+			}
+		}
+	}
+	sort.Ints(ids)
+
+	mismatches := make([]sampleMismatch, 0)
+	for _, id := range ids {
+		presence := make(map[string]string, len(libraries))
+		missing := false
+		for _, lib := range libraries {
+			if _, ok := byID[lib][id]; ok {
+				presence[lib] = "present"
+			} else {
+				presence[lib] = missingValue
+				missing = true
+			}
+		}
+		if missing {
+			mismatches = append(mismatches, sampleMismatch{ID: id, Field: "row", Values: presence})
+			continue
+		}
+
+		for _, f := range sampleFields {
+			values := make(map[string]string, len(libraries))
+			same := true
+			for _, lib := range libraries {
+				values[lib] = f.format(byID[lib][id], precision)
+				if values[lib] != values[libraries[0]] {
+					same = false
+				}
+			}
+			if !same {
+				mismatches = append(mismatches, sampleMismatch{ID: id, Field: f.name, Values: values})
+			}
+		}
+	}
+	return mismatches
+}
+
+// printMismatchTable prints the mismatches side by side, one column per library. When color is set, values that
+// match the first library are green and values that differ are red.
+func printMismatchTable(out io.Writer, mismatches []sampleMismatch, libraries []string, color bool) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprint(w, "ID\tFIELD")
+	for _, lib := range libraries {
+		if color {
+			lib = colorPlain + lib + colorReset
+		}
+		_, _ = fmt.Fprintf(w, "\t%s", lib)
+	}
+	_, _ = fmt.Fprintln(w)
+
+	for _, m := range mismatches {
+		_, _ = fmt.Fprintf(w, "%d\t%s", m.ID, m.Field)
+		expected := m.Values[libraries[0]]
+		for _, lib := range libraries {
+			value := m.Values[lib]
+			if color {
+				// every cell, including the header, gets a color code of the same length so tabwriter keeps the columns aligned
+				c := colorGreen
+				if value != expected {
+					c = colorRed
+				}
+				value = c + value + colorReset
+			}
+			_, _ = fmt.Fprintf(w, "\t%s", value)
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+	_ = w.Flush()
+}
+
+func printMismatchJSON(out io.Writer, mismatches []sampleMismatch) {
+	b, _ := json.MarshalIndent(mismatches, "", "  ")
+	_, _ = fmt.Fprintln(out, string(b))
+}
+
+// isTerminal reports whether f is a character device, used to only color output that is not piped
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
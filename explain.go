@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// capturedPlan is the EXPLAIN ANALYZE output for one captured statement
+type capturedPlan struct {
+	Library       string          `json:"library"`
+	Operation     string          `json:"operation"`
+	SQL           string          `json:"sql"`
+	Plan          json.RawMessage `json:"plan,omitempty"`
+	ExecutionTime float64         `json:"executionTimeMs"`
+	SeqScans      int             `json:"seqScans"`
+	IndexScans    int             `json:"indexScans"`
+	Error         string          `json:"error,omitempty"`
+}
+
+// explainPlanNode is the part of a postgres json plan needed to count scans
+type explainPlanNode struct {
+	NodeType string            `json:"Node Type"`
+	Plans    []explainPlanNode `json:"Plans"`
+}
+
+// explainStatements runs EXPLAIN (ANALYZE, BUFFERS, FORMAT JSON) for every statement. ANALYZE really executes the
+// statement, so each one is run in a transaction that is rolled back.
+func explainStatements(ctx context.Context, db *sql.DB, statements []capturedStatement) []capturedPlan {
+	plans := make([]capturedPlan, 0, len(statements))
+	for _, st := range statements {
+		p := capturedPlan{Library: st.Library, Operation: st.Operation, SQL: st.SQL}
+		if err := explainStatement(ctx, db, st, &p); err != nil {
+			p.Error = err.Error()
+		}
+		plans = append(plans, p)
+	}
+	return plans
+}
+
+func explainStatement(ctx context.Context, db *sql.DB, st capturedStatement, p *capturedPlan) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var raw []byte
+	if err := tx.QueryRowContext(ctx, "explain (analyze, buffers, format json) "+st.SQL, st.Args...).Scan(&raw); err != nil {
+		return err
+	}
+
+	var explained []struct {
+		Plan          explainPlanNode `json:"Plan"`
+		ExecutionTime float64         `json:"Execution Time"`
+	}
+	if err := json.Unmarshal(raw, &explained); err != nil {
+		return err
+	}
+	p.Plan = raw
+	for _, e := range explained {
+		p.ExecutionTime += e.ExecutionTime
+		countScans(e.Plan, p)
+	}
+	return nil
+}
+
+func countScans(node explainPlanNode, p *capturedPlan) {
+	switch {
+	case node.NodeType == "Seq Scan":
+		p.SeqScans++
+	case strings.Contains(node.NodeType, "Index"):
+		// Index Scan, Index Only Scan and Bitmap Index Scan
+		p.IndexScans++
+	}
+	for _, child := range node.Plans {
+		countScans(child, p)
+	}
+}
+
+// printPlanSummary prints the scans and execution time of every explained statement
+func printPlanSummary(plans []capturedPlan) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "LIBRARY\tOPERATION\tSEQ SCANS\tINDEX SCANS\tEXECUTION TIME")
+	seqScans, indexScans := 0, 0
+	for _, p := range plans {
+		if p.Error != "" {
+			_, _ = fmt.Fprintf(w, "%s\t%s\terror: %s\t\t\n", p.Library, p.Operation, p.Error)
+			continue
+		}
+		seqScans += p.SeqScans
+		indexScans += p.IndexScans
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%.3fms\n", p.Library, p.Operation, p.SeqScans, p.IndexScans, p.ExecutionTime)
+	}
+	_, _ = fmt.Fprintf(w, "total\t\t%d\t%d\t\n", seqScans, indexScans)
+	_ = w.Flush()
+}
@@ -0,0 +1,226 @@
+// Package faultproxy is a TCP proxy that injects network faults between a client and a server on command: latency,
+// limited bandwidth, dropped connections, connections reset in the middle of a response, and refusing new
+// connections the way a restarting server does.
+package faultproxy
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// Proxy forwards every connection accepted on its address to the target
+type Proxy struct {
+	listener net.Listener
+	target   string
+
+	mu    sync.Mutex
+	conns map[*link]struct{}
+	// latency delays every chunk forwarded in either direction
+	latency time.Duration
+	// bandwidth limits each direction of each connection to this many bytes a second, 0 is unlimited
+	bandwidth int
+	// resetAfter resets the first connection the target sends this many more bytes on, 0 is off. resetGen counts the
+	// calls to ResetAfter, so each link knows when to start counting again.
+	resetAfter int
+	resetGen   int
+	refusing   bool
+	closed     bool
+
+	wg sync.WaitGroup
+}
+
+// link is a proxied connection, client to proxy and proxy to target
+type link struct {
+	client, server net.Conn
+	// fromServer is how many bytes the target sent since ResetAfter was called resetGen times, guarded by Proxy.mu
+	fromServer int
+	resetGen   int
+}
+
+func (l *link) close() {
+	_ = l.client.Close()
+	_ = l.server.Close()
+}
+
+// Start listens on listenAddr, e.g. "127.0.0.1:0" for any free port, and forwards to target
+func Start(listenAddr, target string) (*Proxy, error) {
+	listener, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return nil, err
+	}
+	p := &Proxy{listener: listener, target: target, conns: map[*link]struct{}{}}
+	p.wg.Add(1)
+	go p.accept()
+	return p, nil
+}
+
+// Addr is the address the proxy listens on
+func (p *Proxy) Addr() string {
+	return p.listener.Addr().String()
+}
+
+// SetLatency delays every chunk of data forwarded in either direction by d, 0 turns it off
+func (p *Proxy) SetLatency(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.latency = d
+}
+
+// SetBandwidth limits each direction of each connection to bytesPerSecond, 0 turns it off
+func (p *Proxy) SetBandwidth(bytesPerSecond int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.bandwidth = bytesPerSecond
+}
+
+// ResetAfter resets the first connection the target sends n more bytes on, the client seeing the response cut off.
+// The bytes are counted for each connection on its own. It only happens once, 0 turns it off.
+func (p *Proxy) ResetAfter(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.resetAfter = n
+	p.resetGen++
+}
+
+// Drop closes every open connection
+func (p *Proxy) Drop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for l := range p.conns {
+		l.close()
+	}
+}
+
+// Refuse closes new connections as soon as they are accepted while refusing is true
+func (p *Proxy) Refuse(refusing bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.refusing = refusing
+}
+
+// Restart drops every connection and refuses new ones for downtime, like the target restarting
+func (p *Proxy) Restart(downtime time.Duration) {
+	p.Refuse(true)
+	p.Drop()
+	time.Sleep(downtime)
+	p.Refuse(false)
+}
+
+// Reset turns off every fault
+func (p *Proxy) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.latency, p.bandwidth, p.resetAfter, p.refusing = 0, 0, 0, false
+}
+
+// Close stops listening, closes every connection and waits for them to finish
+func (p *Proxy) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	err := p.listener.Close()
+	p.Drop()
+	p.wg.Wait()
+	return err
+}
+
+func (p *Proxy) accept() {
+	defer p.wg.Done()
+	for {
+		client, err := p.listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		} else if err != nil {
+			continue
+		}
+		p.mu.Lock()
+		refusing := p.refusing
+		p.mu.Unlock()
+		if refusing {
+			_ = client.Close()
+			continue
+		}
+
+		server, err := net.Dial("tcp", p.target)
+		if err != nil {
+			_ = client.Close()
+			continue
+		}
+		l := &link{client: client, server: server}
+		p.mu.Lock()
+		// Close may have dropped the connections while this one was being dialed
+		if p.closed {
+			p.mu.Unlock()
+			l.close()
+			return
+		}
+		p.conns[l] = struct{}{}
+		p.wg.Add(2)
+		p.mu.Unlock()
+
+		go p.forward(l, client, server, false)
+		go p.forward(l, server, client, true)
+	}
+}
+
+// forward copies from src to dst until either fails, then closes both sides. fromServer is whether src is the
+// target, only data from it can trigger a reset.
+func (p *Proxy) forward(l *link, src, dst net.Conn, fromServer bool) {
+	defer p.wg.Done()
+	defer func() {
+		l.close()
+		p.mu.Lock()
+		delete(p.conns, l)
+		p.mu.Unlock()
+	}()
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			chunk := buf[:n]
+			p.mu.Lock()
+			latency, bandwidth := p.latency, p.bandwidth
+			reset := false
+			if fromServer && p.resetAfter > 0 {
+				if l.resetGen != p.resetGen {
+					l.fromServer, l.resetGen = 0, p.resetGen
+				}
+				if l.fromServer+n >= p.resetAfter {
+					chunk, reset = chunk[:p.resetAfter-l.fromServer], true
+					p.resetAfter = 0
+				} else {
+					l.fromServer += n
+				}
+			}
+			p.mu.Unlock()
+
+			if latency > 0 {
+				time.Sleep(latency)
+			}
+			if bandwidth > 0 {
+				time.Sleep(time.Duration(len(chunk)) * time.Second / time.Duration(bandwidth))
+			}
+			if _, werr := dst.Write(chunk); werr != nil {
+				return
+			}
+			if reset {
+				resetConn(dst)
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// resetConn closes c with a TCP reset rather than an orderly shutdown
+func resetConn(c net.Conn) {
+	if tcp, ok := c.(*net.TCPConn); ok {
+		_ = tcp.SetLinger(0)
+	}
+	_ = c.Close()
+}
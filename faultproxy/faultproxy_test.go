@@ -0,0 +1,158 @@
+package faultproxy
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// startEcho starts a loopback server writing back everything it reads, and a proxy to it
+func startEcho(t *testing.T) *Proxy {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("unable to listen on loopback: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	p, err := Start("127.0.0.1:0", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = p.Close() })
+	return p
+}
+
+func dial(t *testing.T, p *Proxy) net.Conn {
+	t.Helper()
+	conn, err := net.Dial("tcp", p.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+// echo writes data and reads back as much as the proxy forwards before the connection ends or len(data) is read
+func echo(t *testing.T, conn net.Conn, data []byte) ([]byte, error) {
+	t.Helper()
+	if _, err := conn.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, len(data))
+	n, err := io.ReadFull(conn, got)
+	return got[:n], err
+}
+
+func TestForward(t *testing.T) {
+	p := startEcho(t)
+	conn := dial(t, p)
+	for _, data := range []string{"hello", "world"} {
+		if got, err := echo(t, conn, []byte(data)); err != nil || string(got) != data {
+			t.Errorf("echoed %q, %v, want %q", got, err, data)
+		}
+	}
+}
+
+func TestDrop(t *testing.T) {
+	p := startEcho(t)
+	conn := dial(t, p)
+	if _, err := echo(t, conn, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	p.Drop()
+	if n, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Errorf("read %d bytes after the drop, want the connection closed", n)
+	}
+	if got, err := echo(t, dial(t, p), []byte("again")); err != nil || string(got) != "again" {
+		t.Errorf("echoed %q, %v on a new connection, want %q", got, err, "again")
+	}
+}
+
+func TestResetAfter(t *testing.T) {
+	p := startEcho(t)
+	first, second := dial(t, p), dial(t, p)
+
+	p.ResetAfter(8)
+	// every connection counts its own bytes, so 5 on each doesn't reach 8
+	for _, conn := range []net.Conn{first, second} {
+		if got, err := echo(t, conn, []byte("12345")); err != nil || string(got) != "12345" {
+			t.Fatalf("echoed %q, %v, want %q", got, err, "12345")
+		}
+	}
+
+	got, err := echo(t, first, []byte("67890"))
+	if string(got) != "678" {
+		t.Errorf("echoed %q before the reset, want %q", got, "678")
+	}
+	if !errors.Is(err, syscall.ECONNRESET) {
+		t.Errorf("got %v, want the connection reset", err)
+	}
+
+	// the reset only happens once
+	data := bytes.Repeat([]byte("x"), 64)
+	if got, err := echo(t, second, data); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("echoed %d bytes, %v after the reset, want %d", len(got), err, len(data))
+	}
+}
+
+func TestRefuse(t *testing.T) {
+	p := startEcho(t)
+	open := dial(t, p)
+
+	p.Refuse(true)
+	if n, err := dial(t, p).Read(make([]byte, 1)); err == nil {
+		t.Errorf("read %d bytes while refusing, want the connection closed", n)
+	}
+	// connections accepted before are kept
+	if got, err := echo(t, open, []byte("still")); err != nil || string(got) != "still" {
+		t.Errorf("echoed %q, %v while refusing, want %q", got, err, "still")
+	}
+
+	p.Refuse(false)
+	if got, err := echo(t, dial(t, p), []byte("back")); err != nil || string(got) != "back" {
+		t.Errorf("echoed %q, %v after refusing, want %q", got, err, "back")
+	}
+}
+
+func TestCloseWithOpenConnections(t *testing.T) {
+	p := startEcho(t)
+	conn := dial(t, p)
+	if _, err := echo(t, conn, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		if err := p.Close(); err != nil {
+			t.Error(err)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("close is still waiting for the connections")
+	}
+	if _, err := net.Dial("tcp", p.Addr()); err == nil {
+		t.Error("connected after close")
+	}
+}
@@ -0,0 +1,428 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"go-orm-test/faultproxy"
+	"go-orm-test/sqlcdb"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// faultTarget is a library with the connections its operations run on
+type faultTarget struct {
+	name  string
+	ops   libraryOps
+	conns *connections
+	// prepare, when set, is called before every scenario
+	prepare func(ctx context.Context) error
+	// expectRecovery is false for the single pgx.Conn, which never reconnects
+	expectRecovery bool
+}
+
+// faultSettings are how strong the injected faults are
+type faultSettings struct {
+	latency    time.Duration
+	bandwidth  int
+	resetAfter int
+	downtime   time.Duration
+}
+
+// faultScenario turns on a fault and runs an operation with it on, returning the operation's error. The fault is
+// lifted by resetting the proxy once it returns.
+type faultScenario struct {
+	name string
+	run  func(ctx context.Context, p *faultproxy.Proxy, t faultTarget, s faultSettings, id int) error
+}
+
+var faultScenarios = []faultScenario{
+	{
+		name: "latency",
+		run: func(ctx context.Context, p *faultproxy.Proxy, t faultTarget, s faultSettings, id int) error {
+			p.SetLatency(s.latency)
+			_, err := t.ops.getByID(ctx, t.conns, id)
+			return err
+		},
+	},
+	{
+		name: "throttle",
+		run: func(ctx context.Context, p *faultproxy.Proxy, t faultTarget, s faultSettings, _ int) error {
+			p.SetBandwidth(s.bandwidth)
+			_, err := t.ops.listAll(ctx, t.conns)
+			return err
+		},
+	},
+	{
+		name: "drop",
+		run: func(ctx context.Context, p *faultproxy.Proxy, t faultTarget, _ faultSettings, id int) error {
+			p.Drop()
+			_, err := t.ops.getByID(ctx, t.conns, id)
+			return err
+		},
+	},
+	{
+		name: "reset mid-result",
+		run: func(ctx context.Context, p *faultproxy.Proxy, t faultTarget, s faultSettings, _ int) error {
+			p.ResetAfter(s.resetAfter)
+			_, err := t.ops.listAll(ctx, t.conns)
+			return err
+		},
+	},
+	{
+		name: "restart",
+		run: func(ctx context.Context, p *faultproxy.Proxy, t faultTarget, s faultSettings, id int) error {
+			p.Refuse(true)
+			p.Drop()
+			_, err := t.ops.getByID(ctx, t.conns, id)
+			time.Sleep(s.downtime)
+			return err
+		},
+	},
+}
+
+// faultResult is how one target did in one scenario
+type faultResult struct {
+	Target   string        `json:"target"`
+	Scenario string        `json:"scenario"`
+	Elapsed  time.Duration `json:"elapsed"`
+	// Error is what the operation run with the fault on returned
+	Error     string `json:"error,omitempty"`
+	Recovered bool   `json:"recovered"`
+	// FailedAfter counts the operations that failed after the fault was lifted, before one succeeded
+	FailedAfter    int           `json:"failedAfter"`
+	RecoveryTime   time.Duration `json:"recoveryTime"`
+	RecoveryError  string        `json:"recoveryError,omitempty"`
+	ExpectRecovery bool          `json:"expectRecovery"`
+}
+
+// runFaults puts a fault injecting proxy between every library and postgres, then injects each fault in turn and
+// reports what the operation run during it returned and how long the library took to work again once it was lifted.
+// sqlc runs both on its pgxpool and on a single pgx.Conn. It returns 1 if any library but the single pgx.Conn didn't
+// recover.
+func runFaults(ctx context.Context, args []string) int {
+	flags := flag.NewFlagSet("faults", flag.ExitOnError)
+	dsn := flags.String("dsn", connectionString, "connection string")
+	var settings faultSettings
+	flags.DurationVar(&settings.latency, "latency", 50*time.Millisecond, "latency added to every chunk of data in the latency scenario")
+	flags.IntVar(&settings.bandwidth, "bandwidth", 16*1024, "bytes a second each direction is limited to in the throttle scenario")
+	flags.IntVar(&settings.resetAfter, "reset-after", 4*1024, "bytes of the result sent before the connection is reset")
+	flags.DurationVar(&settings.downtime, "downtime", time.Second, "how long postgres refuses connections in the restart scenario")
+	rows := flags.Int("rows", 500, "rows the table is filled up to, so listing it takes long enough to reset or throttle")
+	recoverTimeout := flags.Duration("recover-timeout", 10*time.Second, "how long a library has to work again after a fault is lifted")
+	format := flags.String("format", "table", "output format: table or json")
+	_ = flags.Parse(args)
+
+	id, err := prepareFaultTable(ctx, *dsn, *rows)
+	if err != nil {
+		log.Fatalf("unable to prepare table: %v", err)
+	}
+
+	config, err := pgx.ParseConfig(*dsn)
+	if err != nil {
+		log.Fatalf("invalid connection string: %v", err)
+	}
+	if strings.HasPrefix(config.Host, "/") {
+		log.Fatalf("postgres is reached over the unix socket %s, the proxy needs a tcp address", config.Host)
+	}
+	proxy, err := faultproxy.Start("127.0.0.1:0", net.JoinHostPort(config.Host, strconv.Itoa(int(config.Port))))
+	if err != nil {
+		log.Fatalf("unable to start proxy: %v", err)
+	}
+	defer func() { _ = proxy.Close() }()
+	proxied, err := withAddress(*dsn, proxy.Addr())
+	if err != nil {
+		log.Fatalf("unable to point the connection string at the proxy: %v", err)
+	}
+
+	conns, err := openConnections(ctx, proxied)
+	if err != nil {
+		log.Fatalf("unable to connect: %v", err)
+	}
+	defer conns.Close()
+
+	targets, closeSingle, err := faultTargets(ctx, conns, proxied)
+	if err != nil {
+		log.Fatalf("unable to connect: %v", err)
+	}
+	defer closeSingle()
+
+	results := make([]faultResult, 0, len(targets)*len(faultScenarios))
+	for _, t := range targets {
+		for _, s := range faultScenarios {
+			proxy.Reset()
+			if t.prepare != nil {
+				if err := t.prepare(ctx); err != nil {
+					log.Fatalf("unable to prepare %s: %v", t.name, err)
+				}
+			}
+			results = append(results, runFaultScenario(ctx, proxy, t, s, settings, id, *recoverTimeout))
+		}
+	}
+	proxy.Reset()
+
+	switch *format {
+	case "json":
+		b, _ := json.MarshalIndent(results, "", "  ")
+		fmt.Println(string(b))
+	case "table":
+		printFaultResults(results)
+	default:
+		log.Fatalf("unknown format %q", *format)
+	}
+
+	for _, r := range results {
+		if r.ExpectRecovery && !r.Recovered {
+			return 1
+		}
+	}
+	return 0
+}
+
+// prepareFaultTable migrates and fills the table up to rows, returning the id of a row to get
+func prepareFaultTable(ctx context.Context, dsn string, rows int) (int, error) {
+	conns, err := openConnections(ctx, dsn)
+	if err != nil {
+		return 0, err
+	}
+	defer conns.Close()
+	migrateWithGoose(conns.custom)
+
+	var count int
+	if err := conns.custom.QueryRowContext(ctx, "select count(*) from test.sample_table").Scan(&count); err != nil {
+		return 0, err
+	}
+	if count < rows {
+		_, err := conns.custom.ExecContext(ctx,
+			"insert into test.sample_table (name, description) select 'faults ' || g, repeat('x', 100) from generate_series(1, $1) g",
+			rows-count,
+		)
+		if err != nil {
+			return 0, err
+		}
+	}
+	var id int
+	err = conns.custom.QueryRowContext(ctx, "select id from test.sample_table where deleted_at is null order by id limit 1").Scan(&id)
+	return id, err
+}
+
+// faultTargets are every library on conns, and sqlc on a single pgx.Conn to dsn which is reconnected before every
+// scenario so each starts working. The returned function closes the single connection.
+func faultTargets(ctx context.Context, conns *connections, dsn string) ([]faultTarget, func(), error) {
+	targets := make([]faultTarget, 0, len(libraries)+1)
+	for _, l := range libraries {
+		targets = append(targets, faultTarget{name: l.library, ops: l, conns: conns, expectRecovery: true})
+	}
+
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+	// shares everything with conns but the sqlc queries, which run on the single connection
+	single := *conns
+	single.sqlcQueries = sqlcdb.New(conn)
+	for _, l := range libraries {
+		if l.library != "sqlc" {
+			continue
+		}
+		targets = append(targets, faultTarget{
+			name:  "sqlc pgx.Conn",
+			ops:   l,
+			conns: &single,
+			prepare: func(ctx context.Context) error {
+				if !conn.IsClosed() {
+					return nil
+				}
+				newConn, err := pgx.Connect(ctx, dsn)
+				if err != nil {
+					return err
+				}
+				conn = newConn
+				single.sqlcQueries = sqlcdb.New(conn)
+				return nil
+			},
+		})
+	}
+	return targets, func() { _ = conn.Close(context.Background()) }, nil
+}
+
+// runFaultScenario runs s with target t, then lifts the fault and retries until t works again or timeout passes
+func runFaultScenario(ctx context.Context, p *faultproxy.Proxy, t faultTarget, s faultScenario, settings faultSettings, id int, timeout time.Duration) faultResult {
+	r := faultResult{Target: t.name, Scenario: s.name, ExpectRecovery: t.expectRecovery}
+	opCtx, cancel := context.WithTimeout(ctx, timeout)
+	start := time.Now()
+	err := s.run(opCtx, p, t, settings, id)
+	r.Elapsed = time.Since(start)
+	cancel()
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	p.Reset()
+	lifted := time.Now()
+	for {
+		attemptCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		_, err := t.ops.getByID(attemptCtx, t.conns, id)
+		cancel()
+		if err == nil {
+			r.Recovered = true
+			break
+		}
+		r.FailedAfter++
+		r.RecoveryError = err.Error()
+		if time.Since(lifted) > timeout {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	r.RecoveryTime = time.Since(lifted)
+	if r.Recovered {
+		r.RecoveryError = ""
+	}
+	return r
+}
+
+// withAddress points connectionString at addr instead of the host and port it has
+func withAddress(connectionString, addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(connectionString, "postgres://") || strings.HasPrefix(connectionString, "postgresql://") {
+		u, err := url.Parse(connectionString)
+		if err != nil {
+			return "", err
+		}
+		u.Host = addr
+		return u.String(), nil
+	}
+	// later keywords override earlier ones
+	return connectionString + " host=" + host + " port=" + port, nil
+}
+
+func printFaultResults(results []faultResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "TARGET\tSCENARIO\tELAPSED\tDURING FAULT\tRECOVERED\tFAILED AFTER\tRECOVERY TIME")
+	for _, r := range results {
+		during := "ok"
+		if r.Error != "" {
+			during = "error"
+		}
+		recovered := strconv.FormatBool(r.Recovered)
+		if !r.Recovered && !r.ExpectRecovery {
+			recovered += " (never reconnects)"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n",
+			r.Target, r.Scenario, r.Elapsed.Round(time.Millisecond), during, recovered, r.FailedAfter,
+			r.RecoveryTime.Round(time.Millisecond))
+	}
+	_ = w.Flush()
+
+	for _, r := range results {
+		if r.Error == "" && r.RecoveryError == "" {
+			continue
+		}
+		fmt.Printf("\n%s %s:\n", r.Target, r.Scenario)
+		if r.Error != "" {
+			fmt.Printf("  during fault: %s\n", r.Error)
+		}
+		if r.RecoveryError != "" {
+			fmt.Printf("  after: %s\n", r.RecoveryError)
+		}
+	}
+}
+
+// runProxy runs the fault injecting proxy in front of postgres until stdin is closed, reading the faults to inject
+// from it, one command a line
+func runProxy(_ context.Context, args []string) int {
+	flags := flag.NewFlagSet("proxy", flag.ExitOnError)
+	dsn := flags.String("dsn", connectionString, "connection string of the postgres to proxy")
+	listen := flags.String("listen", "127.0.0.1:6543", "address to listen on")
+	_ = flags.Parse(args)
+
+	config, err := pgx.ParseConfig(*dsn)
+	if err != nil {
+		log.Fatalf("invalid connection string: %v", err)
+	}
+	proxy, err := faultproxy.Start(*listen, net.JoinHostPort(config.Host, strconv.Itoa(int(config.Port))))
+	if err != nil {
+		log.Fatalf("unable to start proxy: %v", err)
+	}
+	defer func() { _ = proxy.Close() }()
+	proxied, _ := withAddress(*dsn, proxy.Addr())
+	log.Printf("proxying %s:%d on %s, connect with %q", config.Host, config.Port, proxy.Addr(), proxied)
+	log.Print("commands: latency <duration>, bandwidth <bytes a second>, reset <bytes>, drop, restart <duration>, refuse, accept, clear")
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if err := proxyCommand(proxy, fields[0], fields[1:]); err != nil {
+			log.Print(err)
+		}
+	}
+	return 0
+}
+
+// proxyCommand injects the fault a line read by runProxy asks for
+func proxyCommand(p *faultproxy.Proxy, command string, args []string) error {
+	arg := func() (string, error) {
+		if len(args) != 1 {
+			return "", fmt.Errorf("%s takes one argument", command)
+		}
+		return args[0], nil
+	}
+	switch command {
+	case "latency", "restart":
+		a, err := arg()
+		if err != nil {
+			return err
+		}
+		d, err := time.ParseDuration(a)
+		if err != nil {
+			return err
+		}
+		if command == "latency" {
+			p.SetLatency(d)
+		} else {
+			p.Restart(d)
+		}
+	case "bandwidth", "reset":
+		a, err := arg()
+		if err != nil {
+			return err
+		}
+		n, err := strconv.Atoi(a)
+		if err != nil {
+			return err
+		}
+		if command == "bandwidth" {
+			p.SetBandwidth(n)
+		} else {
+			p.ResetAfter(n)
+		}
+	case "drop":
+		p.Drop()
+	case "refuse":
+		p.Refuse(true)
+	case "accept":
+		p.Refuse(false)
+	case "clear":
+		p.Reset()
+	default:
+		return fmt.Errorf("unknown command %q", command)
+	}
+	log.Printf("%s %s", command, strings.Join(args, " "))
+	return nil
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"go-orm-test/readiness"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// the generator versions the committed sqlbdb and sqlcdb code was generated with
+const (
+	sqlboilerVersion = "v4.16.2"
+	sqlcVersion      = "v1.20.0"
+)
+
+// generatedDir is a directory of generated code, files without the marker are hand written and are kept when the
+// directory is regenerated
+type generatedDir struct {
+	dir    string
+	marker string
+}
+
+var generatedDirs = []generatedDir{
+	{dir: "sqlbdb", marker: "Code generated by SQLBoiler"},
+	{dir: "sqlcdb", marker: "Code generated by sqlc"},
+	{dir: filepath.Join("sqlite", "sqlbdb"), marker: "Code generated by SQLBoiler"},
+	{dir: filepath.Join("sqlite", "sqlcdb"), marker: "Code generated by sqlc"},
+	{dir: filepath.Join("mysql", "sqlbdb"), marker: "Code generated by SQLBoiler"},
+	{dir: filepath.Join("mysql", "sqlcdb"), marker: "Code generated by sqlc"},
+}
+
+// generateWorkDir holds the new output and the tool binaries while generating, it is removed afterwards
+const generateWorkDir = ".generate"
+
+// runGenerate regenerates sqlbdb and sqlcdb, and their sqlite and mysql variants under sqlite/ and mysql/. The postgres
+// database is migrated first since sqlboiler generates from a live one (configured in sqlboiler.toml), the pinned
+// generator versions are installed if needed, and the output directories are only replaced once every generator
+// succeeded.
+func runGenerate(ctx context.Context, args []string) int {
+	flags := flag.NewFlagSet("generate", flag.ExitOnError)
+	dsn := flags.String("dsn", defaultConnectionString, "connection string of the database sqlboiler.toml points at")
+	boot := flags.Bool("boot", false, "start the database with docker compose first")
+	timeout := flags.Duration("timeout", time.Minute, "how long to wait for the database to be ready")
+	_ = flags.Parse(args)
+
+	if err := generate(ctx, *dsn, *boot, *timeout); err != nil {
+		log.Printf("generate: %v", err)
+		return 1
+	}
+	return 0
+}
+
+func generate(ctx context.Context, dsn string, boot bool, timeout time.Duration) error {
+	if boot {
+		if err := runTool(ctx, nil, "docker", "compose", "-f", "postgres.yml", "up", "-d", "db"); err != nil {
+			return fmt.Errorf("unable to start database: %w", err)
+		}
+	}
+
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return err
+	}
+	defer safeClose(db)
+	ready := waitingForDB
+	ready.Timeout = timeout
+	if err := readiness.Wait(ctx, ready, db.PingContext); err != nil {
+		return err
+	}
+	if err := migrateAndSeed(ctx, db); err != nil {
+		return fmt.Errorf("unable to migrate: %w", err)
+	}
+
+	bin, err := installGenerators(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(generateWorkDir); err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(generateWorkDir) }()
+
+	// sqlboiler
+	if err := runTool(ctx, nil, filepath.Join(bin, "sqlboiler"),
+		"-c", "sqlboiler.toml",
+		"--add-soft-deletes",
+		"--no-rows-affected",
+		"--no-tests",
+		"-o", filepath.Join(generateWorkDir, "sqlbdb"),
+		"-p", "sqlbdb",
+		filepath.Join(bin, "sqlboiler-psql"),
+	); err != nil {
+		return fmt.Errorf("sqlboiler: %w", err)
+	}
+
+	// sqlboiler for sqlite reads the schema from a database file, so sqlite/schema.sql is applied to a new one first
+	sqliteSchema := filepath.Join(generateWorkDir, "sqlite.db")
+	if err := createSQLiteSchema(ctx, sqliteSchema, "sqlite/schema.sql"); err != nil {
+		return fmt.Errorf("sqlite schema: %w", err)
+	}
+	if err := runTool(ctx, append(os.Environ(), "SQLITE3_DBNAME="+sqliteSchema), filepath.Join(bin, "sqlboiler"),
+		"--add-soft-deletes",
+		"--no-rows-affected",
+		"--no-tests",
+		"-o", filepath.Join(generateWorkDir, "sqlite", "sqlbdb"),
+		"-p", "sqlbdb",
+		filepath.Join(bin, "sqlboiler-sqlite3"),
+	); err != nil {
+		return fmt.Errorf("sqlboiler sqlite: %w", err)
+	}
+
+	// and for mysql from a live database, mysql/schema.sql is applied to an in-process server
+	mysqlDSN, stopMySQL, err := startMySQLServer()
+	if err != nil {
+		return fmt.Errorf("mysql server: %w", err)
+	}
+	defer stopMySQL()
+	mysqlEnv, err := createMySQLSchema(ctx, mysqlDSN, "mysql/schema.sql")
+	if err != nil {
+		return fmt.Errorf("mysql schema: %w", err)
+	}
+	if err := runTool(ctx, append(os.Environ(), mysqlEnv...), filepath.Join(bin, "sqlboiler"),
+		"--add-soft-deletes",
+		"--no-rows-affected",
+		"--no-tests",
+		"-o", filepath.Join(generateWorkDir, "mysql", "sqlbdb"),
+		"-p", "sqlbdb",
+		filepath.Join(bin, "sqlboiler-mysql"),
+	); err != nil {
+		return fmt.Errorf("sqlboiler mysql: %w", err)
+	}
+
+	for _, config := range []string{"sqlc.yaml", "sqlite/sqlc.yaml", "mysql/sqlc.yaml"} {
+		if err := generateSqlc(ctx, bin, config); err != nil {
+			return fmt.Errorf("sqlc %s: %w", config, err)
+		}
+	}
+
+	return swapGeneratedDirs()
+}
+
+// generateSqlc runs sqlc with a copy of config writing to the work dir. sqlc resolves paths relative to the config
+// file so the copy is kept next to the original.
+func generateSqlc(ctx context.Context, bin, config string) error {
+	contents, err := os.ReadFile(config)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(config)
+	out, err := filepath.Rel(dir, filepath.Join(generateWorkDir, dir, "sqlcdb"))
+	if err != nil {
+		return err
+	}
+	newContents := strings.Replace(string(contents), `out: "sqlcdb"`, fmt.Sprintf(`out: "%s"`, filepath.ToSlash(out)), 1)
+	if newContents == string(contents) {
+		return fmt.Errorf(`%s no longer has out: "sqlcdb"`, config)
+	}
+	generateConfig := filepath.Join(dir, "sqlc.generate.yaml")
+	if err := os.WriteFile(generateConfig, []byte(newContents), 0o644); err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(generateConfig) }()
+	return runTool(ctx, nil, filepath.Join(bin, "sqlc"), "generate", "-f", generateConfig)
+}
+
+// createSQLiteSchema creates the sqlite database file path with the schema in schemaFile applied
+func createSQLiteSchema(ctx context.Context, path, schemaFile string) error {
+	schema, err := os.ReadFile(schemaFile)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	defer safeClose(db)
+	_, err = db.ExecContext(ctx, string(schema))
+	return err
+}
+
+// createMySQLSchema applies the schema in schemaFile to the mysql database in dsn, returning the environment that
+// points sqlboiler-mysql at it
+func createMySQLSchema(ctx context.Context, dsn, schemaFile string) ([]string, error) {
+	schema, err := os.ReadFile(schemaFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer safeClose(db)
+	if _, err := db.ExecContext(ctx, string(schema)); err != nil {
+		return nil, err
+	}
+	host, port, err := net.SplitHostPort(cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+	return []string{
+		"MYSQL_HOST=" + host,
+		"MYSQL_PORT=" + port,
+		"MYSQL_DBNAME=" + cfg.DBName,
+		"MYSQL_USER=" + cfg.User,
+		"MYSQL_PASS=" + cfg.Passwd,
+		"MYSQL_SSLMODE=false",
+	}, nil
+}
+
+// installGenerators installs the pinned sqlboiler, its psql, sqlite3 and mysql drivers, and sqlc into a cache directory
+// per version, returning the directory
+func installGenerators(ctx context.Context) (string, error) {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	bin := filepath.Join(cache, "go-sql-playground", fmt.Sprintf("sqlboiler-%s_sqlc-%s", sqlboilerVersion, sqlcVersion))
+	env := append(os.Environ(), "GOBIN="+bin)
+
+	tools := map[string]string{
+		"sqlboiler":         "github.com/volatiletech/sqlboiler/v4@" + sqlboilerVersion,
+		"sqlboiler-psql":    "github.com/volatiletech/sqlboiler/v4/drivers/sqlboiler-psql@" + sqlboilerVersion,
+		"sqlboiler-sqlite3": "github.com/volatiletech/sqlboiler/v4/drivers/sqlboiler-sqlite3@" + sqlboilerVersion,
+		"sqlboiler-mysql":   "github.com/volatiletech/sqlboiler/v4/drivers/sqlboiler-mysql@" + sqlboilerVersion,
+		"sqlc":              "github.com/sqlc-dev/sqlc/cmd/sqlc@" + sqlcVersion,
+	}
+	for name, pkg := range tools {
+		if _, err := os.Stat(filepath.Join(bin, name)); err == nil {
+			continue
+		}
+		if err := runTool(ctx, env, "go", "install", pkg); err != nil {
+			return "", fmt.Errorf("unable to install %s: %w", pkg, err)
+		}
+	}
+	return bin, nil
+}
+
+// swapGeneratedDirs replaces each generated directory with the new output in the work dir, carrying over hand written
+// files. If any rename fails the directories already swapped are put back.
+func swapGeneratedDirs() error {
+	for _, g := range generatedDirs {
+		if err := copyHandWrittenFiles(g, filepath.Join(generateWorkDir, g.dir)); err != nil {
+			return err
+		}
+	}
+
+	swapped := make([]generatedDir, 0, len(generatedDirs))
+	restore := func() {
+		for _, g := range swapped {
+			_ = os.RemoveAll(g.dir)
+			_ = os.Rename(filepath.Join(generateWorkDir, "old", g.dir), g.dir)
+		}
+	}
+	for _, g := range generatedDirs {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(generateWorkDir, "old", g.dir)), 0o755); err != nil {
+			restore()
+			return err
+		}
+		if err := os.Rename(g.dir, filepath.Join(generateWorkDir, "old", g.dir)); err != nil {
+			restore()
+			return err
+		}
+		if err := os.Rename(filepath.Join(generateWorkDir, g.dir), g.dir); err != nil {
+			_ = os.Rename(filepath.Join(generateWorkDir, "old", g.dir), g.dir)
+			restore()
+			return err
+		}
+		swapped = append(swapped, g)
+	}
+	return nil
+}
+
+// copyHandWrittenFiles copies the files in the generated directory that are not generated into the new output
+func copyHandWrittenFiles(g generatedDir, to string) error {
+	entries, err := os.ReadDir(g.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		contents, err := os.ReadFile(filepath.Join(g.dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		if strings.Contains(string(contents), g.marker) {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(to, entry.Name()), contents, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runTool runs a command with its output going to ours, env is the command's environment or nil for ours
+func runTool(ctx context.Context, env []string, name string, args ...string) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = env
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	log.Printf("running %s %s", name, strings.Join(args, " "))
+	return cmd.Run()
+}
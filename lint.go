@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// lintIgnorePrefix on the line before a statement, or anywhere in it, skips the named rules for that statement,
+// e.g. "-- lint:ignore column-type-change the table is tiny"
+const lintIgnorePrefix = "-- lint:ignore"
+
+// lintFinding is one risky operation found in a migration
+type lintFinding struct {
+	file       string
+	line       int
+	rule       string
+	message    string
+	suggestion string
+}
+
+// migrationStatement is one statement of a migration with the line it starts on
+type migrationStatement struct {
+	sql     string // lower case, comments removed and whitespace collapsed
+	line    int
+	up      bool
+	ignored map[string]bool
+}
+
+var (
+	lintCreateTable = regexp.MustCompile(`^create table (?:if not exists )?([\w."]+)`)
+	lintCreateIndex = regexp.MustCompile(`^create (?:unique )?index (concurrently )?.*\bon (?:only )?([\w."]+)`)
+	lintAlterTable  = regexp.MustCompile(`^alter table (?:only )?(?:if exists )?([\w."]+) (.*)$`)
+
+	// clauses of an alter table
+	lintAddNotNull = regexp.MustCompile(`^add (?:column )?(?:if not exists )?(\w+) .*\bnot null\b`)
+	lintHasDefault = regexp.MustCompile(`\bdefault\b`)
+	lintAlterType  = regexp.MustCompile(`^alter (?:column )?(\w+) (?:set data )?type\b`)
+	lintDropColumn = regexp.MustCompile(`^drop (?:column )?(?:if exists )?(\w+)`)
+)
+
+// runMigrateLint checks every embedded migration for operations that are risky on a live database
+func runMigrateLint(_ []string) int {
+	queries, err := os.ReadFile("query.sql")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	entries, err := fs.ReadDir(embedMigrations, migrationsDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	findings := make([]lintFinding, 0)
+	for _, entry := range entries {
+		name := path.Join(migrationsDir, entry.Name())
+		contents, err := fs.ReadFile(embedMigrations, name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		findings = append(findings, lintMigration(name, contents, queries)...)
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].file != findings[j].file {
+			return findings[i].file < findings[j].file
+		}
+		return findings[i].line < findings[j].line
+	})
+	for _, f := range findings {
+		fmt.Printf("%s:%d: %s: %s\n    suggestion: %s\n", f.file, f.line, f.rule, f.message, f.suggestion)
+	}
+	if len(findings) > 0 {
+		return 1
+	}
+	fmt.Println("no risky operations found")
+	return 0
+}
+
+// lintMigration returns the findings for one migration file, queries is query.sql used to find dropped columns that
+// are still used
+func lintMigration(file string, contents, queries []byte) []lintFinding {
+	statements, hasDown := splitMigration(contents)
+	findings := make([]lintFinding, 0)
+	add := func(s migrationStatement, rule, message, suggestion string) {
+		if !s.ignored[rule] {
+			findings = append(findings, lintFinding{file, s.line, rule, message, suggestion})
+		}
+	}
+
+	if !hasDown {
+		findings = append(findings, lintFinding{file, 1, "missing-down",
+			"there is no -- +goose Down section, or it is empty",
+			"add a Down section reversing the Up section, and check it with `go run . migrate roundtrip`",
+		})
+	}
+
+	// tables created in this migration are empty, so the rules about rewriting and locking do not apply to them
+	created := map[string]bool{}
+	for _, s := range statements {
+		if !s.up {
+			continue
+		}
+		if m := lintCreateTable.FindStringSubmatch(s.sql); m != nil {
+			created[m[1]] = true
+			continue
+		}
+
+		if m := lintCreateIndex.FindStringSubmatch(s.sql); m != nil && m[1] == "" && !created[m[2]] {
+			add(s, "index-not-concurrent",
+				fmt.Sprintf("creating an index on %s without concurrently blocks writes to it until the index is built", m[2]),
+				"use create index concurrently in its own migration marked -- +goose NO TRANSACTION",
+			)
+		}
+
+		m := lintAlterTable.FindStringSubmatch(s.sql)
+		if m == nil || created[m[1]] {
+			continue
+		}
+		table := m[1]
+		for _, clause := range splitClauses(m[2]) {
+			if c := lintAddNotNull.FindStringSubmatch(clause); c != nil && !lintHasDefault.MatchString(clause) {
+				add(s, "not-null-without-default",
+					fmt.Sprintf("adding not null column %s to %s without a default fails if the table has rows", c[1], table),
+					"add the column with a default, or add it nullable, backfill it, then add a not valid check constraint and validate it",
+				)
+			}
+			if c := lintAlterType.FindStringSubmatch(clause); c != nil {
+				add(s, "column-type-change",
+					fmt.Sprintf("changing the type of %s.%s usually rewrites the table under an access exclusive lock", table, c[1]),
+					"add a column with the new type, backfill it in batches, switch the code over, then drop the old column",
+				)
+			}
+			if c := lintDropColumn.FindStringSubmatch(clause); c != nil && c[1] != "constraint" {
+				if lines := queryLinesUsing(queries, table, c[1]); len(lines) > 0 {
+					add(s, "dropped-column-in-use",
+						fmt.Sprintf("column %s.%s is dropped but query.sql still uses it on line %s", table, c[1], joinInts(lines)),
+						"remove it from query.sql and regenerate first, then drop the column in a later release",
+					)
+				}
+			}
+		}
+	}
+	return findings
+}
+
+// splitMigration splits a goose migration into statements, and reports whether it has a non empty down section
+func splitMigration(contents []byte) ([]migrationStatement, bool) {
+	statements := make([]migrationStatement, 0)
+	hasDown := false
+	up := false
+	var current strings.Builder
+	start := 0
+	ignored := map[string]bool{}
+
+	flush := func() {
+		s := strings.Join(strings.Fields(strings.ToLower(current.String())), " ")
+		if s != "" {
+			statements = append(statements, migrationStatement{sql: s, line: start, up: up, ignored: ignored})
+			if !up {
+				hasDown = true
+			}
+		}
+		current.Reset()
+		start = 0
+		ignored = map[string]bool{}
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "-- +goose Up"):
+			flush()
+			up = true
+			continue
+		case strings.HasPrefix(trimmed, "-- +goose Down"):
+			flush()
+			up = false
+			continue
+		case strings.HasPrefix(trimmed, lintIgnorePrefix):
+			for _, rule := range strings.Fields(strings.TrimPrefix(trimmed, lintIgnorePrefix)) {
+				ignored[rule] = true
+			}
+			continue
+		}
+
+		// comments are dropped, this does not handle -- inside strings, which migrations here don't have
+		if i := strings.Index(line, "--"); i >= 0 {
+			line = line[:i]
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if start == 0 {
+			start = lineNumber
+		}
+		for {
+			i := strings.Index(line, ";")
+			if i < 0 {
+				current.WriteString(line + "\n")
+				break
+			}
+			current.WriteString(line[:i])
+			flush()
+			line = line[i+1:]
+			if strings.TrimSpace(line) == "" {
+				break
+			}
+			start = lineNumber
+		}
+	}
+	flush()
+	return statements, hasDown
+}
+
+// splitClauses splits the actions of an alter table on the commas that are not inside parentheses
+func splitClauses(actions string) []string {
+	clauses := make([]string, 0)
+	depth, start := 0, 0
+	for i, r := range actions {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				clauses = append(clauses, strings.TrimSpace(actions[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(clauses, strings.TrimSpace(actions[start:]))
+}
+
+// lintSelectStar matches a select * or returning *, which the generated code scans every column of the table for
+var lintSelectStar = regexp.MustCompile(`\b(?:select|returning)\s+\*`)
+
+// queryLinesUsing returns the lines of query.sql that use the column of table, either by naming it or with a select *
+// or returning * in a query on the table
+func queryLinesUsing(queries []byte, table, column string) []int {
+	word := regexp.MustCompile(`\b` + regexp.QuoteMeta(column) + `\b`)
+	onTable := regexp.MustCompile(`(?:^|[^\w.])` + regexp.QuoteMeta(strings.ReplaceAll(table, `"`, "")) + `\b`)
+
+	lines := make([]int, 0)
+	// the lines of the query read so far, as * and the table it is from are often on different lines
+	type queryLine struct {
+		number int
+		text   string
+	}
+	query := make([]queryLine, 0)
+	flush := func() {
+		var text strings.Builder
+		for _, l := range query {
+			text.WriteString(l.text + "\n")
+		}
+		fromTable := onTable.MatchString(strings.ReplaceAll(text.String(), `"`, ""))
+		for _, l := range query {
+			if word.MatchString(l.text) || fromTable && lintSelectStar.MatchString(l.text) {
+				lines = append(lines, l.number)
+			}
+		}
+		query = query[:0]
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(queries))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.ToLower(scanner.Text())
+		if strings.HasPrefix(strings.TrimSpace(line), "--") {
+			continue
+		}
+		query = append(query, queryLine{lineNumber, line})
+		if strings.Contains(line, ";") {
+			flush()
+		}
+	}
+	flush()
+	return lines
+}
+
+func joinInts(ints []int) string {
+	s := make([]string, 0, len(ints))
+	for _, i := range ints {
+		s = append(s, fmt.Sprint(i))
+	}
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// testLintQueries reads slug by name, and every column of sample_table through select * and returning *
+var testLintQueries = []byte(`-- name: GetAllSamples :many
+select * from test.sample_table;
+
+-- name: CreateSampleWithReturn :one
+insert into test.sample_table (name)
+values ($1) returning *;
+
+-- name: GetSlugs :many
+select slug from test.sample_table;
+
+-- name: GetOthers :many
+select * from test.other_table;
+`)
+
+func TestLintMigration(t *testing.T) {
+	tests := []struct {
+		name      string
+		migration string
+		// rules found, with the line each was found on
+		want []string
+	}{
+		{
+			name:      "missing down",
+			migration: "-- +goose Up\nalter table test.sample_table add column extra text;\n",
+			want:      []string{"1 missing-down"},
+		},
+		{
+			name:      "index without concurrently",
+			migration: "-- +goose Up\ncreate index sample_name on test.sample_table (name);\n-- +goose Down\ndrop index sample_name;\n",
+			want:      []string{"2 index-not-concurrent"},
+		},
+		{
+			name:      "index concurrently",
+			migration: "-- +goose Up\ncreate index concurrently sample_name on test.sample_table (name);\n-- +goose Down\ndrop index sample_name;\n",
+			want:      []string{},
+		},
+		{
+			name: "index on a table created in the migration",
+			migration: "-- +goose Up\ncreate table test.new (id int, name text);\ncreate index new_name on test.new (name);\n" +
+				"-- +goose Down\ndrop table test.new;\n",
+			want: []string{},
+		},
+		{
+			name: "not null with and without a default",
+			migration: "-- +goose Up\nalter table test.sample_table\n    add column a int not null,\n    add column b int not null default 0;\n" +
+				"-- +goose Down\nalter table test.sample_table drop column a, drop column b;\n",
+			want: []string{"2 not-null-without-default"},
+		},
+		{
+			name:      "type change",
+			migration: "-- +goose Up\nalter table test.sample_table alter column name type varchar(100);\n-- +goose Down\nselect 1;\n",
+			want:      []string{"2 column-type-change"},
+		},
+		{
+			name: "ignored type change",
+			migration: "-- +goose Up\n-- lint:ignore column-type-change the table is tiny\n" +
+				"alter table test.sample_table alter column name type varchar(100);\n-- +goose Down\nselect 1;\n",
+			want: []string{},
+		},
+		{
+			name:      "dropping a column read by name and by select *",
+			migration: "-- +goose Up\nalter table test.sample_table drop column slug;\n-- +goose Down\nselect 1;\n",
+			want:      []string{"2 dropped-column-in-use"},
+		},
+		{
+			name:      "dropping a column only read by select *",
+			migration: "-- +goose Up\nalter table test.sample_table drop column deleted_at;\n-- +goose Down\nselect 1;\n",
+			want:      []string{"2 dropped-column-in-use"},
+		},
+		{
+			name:      "dropping a column of a table query.sql doesn't read",
+			migration: "-- +goose Up\nalter table test.unused drop column deleted_at;\n-- +goose Down\nselect 1;\n",
+			want:      []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]string, 0)
+			for _, f := range lintMigration("test.sql", []byte(tt.migration), testLintQueries) {
+				got = append(got, fmt.Sprintf("%d %s", f.line, f.rule))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("found %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryLinesUsing(t *testing.T) {
+	tests := []struct {
+		table, column string
+		want          []int
+	}{
+		// select * on line 2, returning * on line 6, named on line 9
+		{"test.sample_table", "slug", []int{2, 6, 9}},
+		{"test.sample_table", "created_at", []int{2, 6}},
+		{`"test"."sample_table"`, "created_at", []int{2, 6}},
+		{"test.other_table", "created_at", []int{12}},
+		{"test.unused", "created_at", []int{}},
+	}
+	for _, tt := range tests {
+		if got := queryLinesUsing(testLintQueries, tt.table, tt.column); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s.%s is used on lines %v, want %v", tt.table, tt.column, got, tt.want)
+		}
+	}
+}
+
+func TestSplitMigration(t *testing.T) {
+	tests := []struct {
+		name      string
+		migration string
+		want      []migrationStatement
+		hasDown   bool
+	}{
+		{
+			name: "up and down",
+			migration: "-- +goose Up\ncreate table t\n(\n    id int -- the key\n);\n\n" +
+				"-- +goose Down\ndrop table t;\n",
+			want: []migrationStatement{
+				{sql: "create table t ( id int )", line: 2, up: true, ignored: map[string]bool{}},
+				{sql: "drop table t", line: 8, up: false, ignored: map[string]bool{}},
+			},
+			hasDown: true,
+		},
+		{
+			name:      "several statements on a line",
+			migration: "-- +goose Up\nselect 1; SELECT 2;\n-- +goose Down\n",
+			want: []migrationStatement{
+				{sql: "select 1", line: 2, up: true, ignored: map[string]bool{}},
+				{sql: "select 2", line: 2, up: true, ignored: map[string]bool{}},
+			},
+		},
+		{
+			name:      "ignored rules apply to the next statement only",
+			migration: "-- +goose Up\n-- lint:ignore a b\nselect 1;\nselect 2;\n",
+			want: []migrationStatement{
+				{sql: "select 1", line: 3, up: true, ignored: map[string]bool{"a": true, "b": true}},
+				{sql: "select 2", line: 4, up: true, ignored: map[string]bool{}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, hasDown := splitMigration([]byte(tt.migration))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("statements are %+v, want %+v", got, tt.want)
+			}
+			if hasDown != tt.hasDown {
+				t.Errorf("has down is %t, want %t", hasDown, tt.hasDown)
+			}
+		})
+	}
+}
+
+func TestSplitClauses(t *testing.T) {
+	tests := []struct {
+		actions string
+		want    []string
+	}{
+		{"add column a int", []string{"add column a int"}},
+		{"add column a int, drop column b", []string{"add column a int", "drop column b"}},
+		{
+			"add constraint c check (x in (1, 2)), alter column d type numeric(10, 2)",
+			[]string{"add constraint c check (x in (1, 2))", "alter column d type numeric(10, 2)"},
+		},
+	}
+	for _, tt := range tests {
+		if got := splitClauses(tt.actions); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q splits into %q, want %q", tt.actions, got, tt.want)
+		}
+	}
+}
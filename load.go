@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// loadFunc runs one operation with library l, ids are the rows inserted before the load started
+type loadFunc func(ctx context.Context, c *connections, l libraryOps, ids []int, rnd *rand.Rand) error
+
+// loadOperation is one kind of operation in the load mix, run with the given share of the operations
+type loadOperation struct {
+	name   string
+	weight int
+	run    loadFunc
+}
+
+// loadOperations are the operations a load mix can be made of
+var loadOperations = map[string]loadFunc{
+	"get": func(ctx context.Context, c *connections, l libraryOps, ids []int, rnd *rand.Rand) error {
+		_, err := l.getByID(ctx, c, ids[rnd.Intn(len(ids))])
+		return err
+	},
+	"insert": func(ctx context.Context, c *connections, l libraryOps, _ []int, rnd *rand.Rand) error {
+		return l.insert(ctx, c, Sample{Name: fmt.Sprintf("%s load %d", l.library, rnd.Int63())})
+	},
+	"update": func(ctx context.Context, c *connections, l libraryOps, ids []int, rnd *rand.Rand) error {
+		return l.updateName(ctx, c, ids[rnd.Intn(len(ids))], fmt.Sprintf("%s load %d", l.library, rnd.Int63()))
+	},
+}
+
+// loadRows is how many rows each library inserts before the load starts, for gets and updates to pick from
+const loadRows = 100
+
+// loadResult is the outcome of one operation, or "all" of them, for one library
+type loadResult struct {
+	Library   string        `json:"library"`
+	Operation string        `json:"operation"`
+	Count     int           `json:"count"`
+	Errors    int           `json:"errors"`
+	ErrorRate float64       `json:"errorRate"`
+	PerSecond float64       `json:"perSecond"`
+	P50       time.Duration `json:"p50"`
+	P95       time.Duration `json:"p95"`
+	P99       time.Duration `json:"p99"`
+	// FirstError is the first error the operation returned, the others are usually the same
+	FirstError string `json:"firstError,omitempty"`
+}
+
+// loadTiming is one operation run by a load worker
+type loadTiming struct {
+	operation string
+	took      time.Duration
+	err       error
+}
+
+// runLoad runs a mix of operations with each library in turn, from many goroutines for a fixed duration, and reports
+// the throughput, latency percentiles and error rate of every operation. It returns 1 if any operation failed.
+func runLoad(ctx context.Context, args []string) int {
+	flags := flag.NewFlagSet("load", flag.ExitOnError)
+	dsn := flags.String("dsn", connectionString, "connection string")
+	mix := flags.String("mix", "get=80,insert=15,update=5", "comma separated operation=weight, the operations are get, insert and update")
+	workers := flags.Int("concurrency", 8, "goroutines per library")
+	duration := flags.Duration("duration", 10*time.Second, "how long each library runs")
+	format := flags.String("format", "table", "output format: table or json")
+	_ = flags.Parse(args)
+
+	operations, err := parseLoadMix(*mix)
+	if err != nil {
+		log.Fatalf("invalid -mix: %v", err)
+	}
+	if *workers < 1 {
+		log.Fatalf("-concurrency must be at least 1")
+	}
+
+	conns, err := openConnections(ctx, *dsn)
+	if err != nil {
+		log.Fatalf("unable to connect: %v", err)
+	}
+	defer conns.Close()
+	migrateWithGoose(conns.custom)
+
+	results := make([]loadResult, 0, len(libraries)*(len(operations)+1))
+	for _, l := range libraries {
+		log.Printf("running %s load for %s", l.library, *duration)
+		results = append(results, runLibraryLoad(ctx, conns, l, operations, *workers, *duration)...)
+	}
+
+	switch *format {
+	case "json":
+		b, _ := json.MarshalIndent(results, "", "  ")
+		fmt.Println(string(b))
+	case "table":
+		printLoadResults(results)
+	default:
+		log.Fatalf("unknown format %q", *format)
+	}
+
+	for _, r := range results {
+		if r.Errors > 0 {
+			return 1
+		}
+	}
+	return 0
+}
+
+// parseLoadMix parses operation=weight pairs, the weights don't need to add up to 100
+func parseLoadMix(s string) ([]loadOperation, error) {
+	var operations []loadOperation
+	for _, field := range strings.Split(s, ",") {
+		name, weight, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			return nil, fmt.Errorf("%q is not operation=weight", field)
+		}
+		run, ok := loadOperations[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown operation %q", name)
+		}
+		w, err := strconv.Atoi(weight)
+		if err != nil || w < 0 {
+			return nil, fmt.Errorf("weight of %s is not a number of at least 0", name)
+		}
+		if w > 0 {
+			operations = append(operations, loadOperation{name: name, weight: w, run: run})
+		}
+	}
+	if len(operations) == 0 {
+		return nil, fmt.Errorf("no operation has a weight")
+	}
+	return operations, nil
+}
+
+// runLibraryLoad runs the mix with library l on workers goroutines until duration has passed, and returns a result
+// per operation followed by one for all of them
+func runLibraryLoad(ctx context.Context, c *connections, l libraryOps, operations []loadOperation, workers int, duration time.Duration) []loadResult {
+	ids := make([]int, 0, loadRows)
+	for i := 0; i < loadRows; i++ {
+		s, err := l.insertReturning(ctx, c, Sample{Name: fmt.Sprintf("%s load seed %d", l.library, i)})
+		if err != nil {
+			return []loadResult{{Library: l.library, Operation: "all", Errors: 1, ErrorRate: 1, FirstError: err.Error()}}
+		}
+		ids = append(ids, s.ID)
+	}
+
+	total := 0
+	for _, op := range operations {
+		total += op.weight
+	}
+
+	timings := make([][]loadTiming, workers)
+	var wg sync.WaitGroup
+	start := time.Now()
+	deadline := start.Add(duration)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(start.UnixNano() + int64(w)))
+			for time.Now().Before(deadline) {
+				pick := rnd.Intn(total)
+				op := operations[0]
+				for _, o := range operations {
+					if pick < o.weight {
+						op = o
+						break
+					}
+					pick -= o.weight
+				}
+				opStart := time.Now()
+				err := op.run(ctx, c, l, ids, rnd)
+				timings[w] = append(timings[w], loadTiming{operation: op.name, took: time.Since(opStart), err: err})
+			}
+		}(w)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	byOperation := make(map[string][]loadTiming, len(operations))
+	var all []loadTiming
+	for _, ts := range timings {
+		for _, t := range ts {
+			byOperation[t.operation] = append(byOperation[t.operation], t)
+		}
+		all = append(all, ts...)
+	}
+
+	results := make([]loadResult, 0, len(operations)+1)
+	for _, op := range operations {
+		results = append(results, summarizeLoad(l.library, op.name, byOperation[op.name], elapsed))
+	}
+	return append(results, summarizeLoad(l.library, "all", all, elapsed))
+}
+
+// summarizeLoad computes the throughput, error rate and latency percentiles of timings
+func summarizeLoad(library, operation string, timings []loadTiming, elapsed time.Duration) loadResult {
+	r := loadResult{Library: library, Operation: operation, Count: len(timings)}
+	if len(timings) == 0 {
+		return r
+	}
+	took := make([]time.Duration, 0, len(timings))
+	for _, t := range timings {
+		took = append(took, t.took)
+		if t.err != nil {
+			if r.Errors == 0 {
+				r.FirstError = t.err.Error()
+			}
+			r.Errors++
+		}
+	}
+	sort.Slice(took, func(i, j int) bool { return took[i] < took[j] })
+	r.ErrorRate = float64(r.Errors) / float64(r.Count)
+	r.PerSecond = float64(r.Count) / elapsed.Seconds()
+	r.P50, r.P95, r.P99 = percentile(took, 0.50), percentile(took, 0.95), percentile(took, 0.99)
+	return r
+}
+
+// percentile is the nearest rank percentile p, between 0 and 1, of the sorted durations
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(p*float64(len(sorted))+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+func printLoadResults(results []loadResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "LIBRARY\tOPERATION\tCOUNT\tOPS/S\tERRORS\tERROR RATE\tP50\tP95\tP99")
+	for _, r := range results {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%d\t%.0f\t%d\t%.2f%%\t%s\t%s\t%s\n",
+			r.Library, r.Operation, r.Count, r.PerSecond, r.Errors, r.ErrorRate*100,
+			r.P50.Round(time.Microsecond), r.P95.Round(time.Microsecond), r.P99.Round(time.Microsecond))
+	}
+	_ = w.Flush()
+
+	for _, r := range results {
+		// all repeats the errors of the operations, unless the load never started
+		if r.FirstError != "" && (r.Operation != "all" || r.Count == 0) {
+			fmt.Printf("%s %s: %s\n", r.Library, r.Operation, r.FirstError)
+		}
+	}
+}
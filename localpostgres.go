@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"go-orm-test/readiness"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+)
+
+// localPostgresOptions configure startLocalPostgres, they are set from the -local-postgres flags
+type localPostgresOptions struct {
+	// bin is the directory holding initdb and pg_ctl, they are looked up on PATH when empty
+	bin string
+	// readiness is how long and how often to check whether the server accepts connections yet
+	readiness readiness.Config
+}
+
+// startLocalPostgres starts a throwaway server in a temporary directory with initdb and pg_ctl, with the user,
+// password and database of postgres.yml, and returns a connection string for it along with a function that stops it
+// and removes the directory
+func startLocalPostgres(ctx context.Context, opts localPostgresOptions) (string, func(), error) {
+	initdb, err := postgresBinary(opts.bin, "initdb")
+	if err != nil {
+		return "", nil, err
+	}
+	pgCtl, err := postgresBinary(opts.bin, "pg_ctl")
+	if err != nil {
+		return "", nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "go-orm-test-postgres-")
+	if err != nil {
+		return "", nil, err
+	}
+	data := filepath.Join(dir, "data")
+	logFile := filepath.Join(dir, "postgres.log")
+	passwordFile := filepath.Join(dir, "password")
+	if err := os.WriteFile(passwordFile, []byte("supersecret\n"), 0o600); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", nil, err
+	}
+
+	if err := runQuiet(ctx, initdb, "-D", data, "-U", "localuser", "--pwfile", passwordFile,
+		"--auth", "scram-sha-256", "--encoding", "UTF8", "--locale", "C"); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", nil, fmt.Errorf("initdb: %w", err)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return "", nil, err
+	}
+	// the socket goes in the temp dir too, the default one usually needs root to create
+	serverOptions := fmt.Sprintf("-p %d -k %s -c listen_addresses=127.0.0.1", port, dir)
+	if err := runQuiet(ctx, pgCtl, "start", "-W", "-D", data, "-l", logFile, "-o", serverOptions); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", nil, fmt.Errorf("pg_ctl start: %w", err)
+	}
+
+	// log.Fatal and signals end the process without running stop, the watchdog stops the server once this process
+	// is gone. It ignores the signals a terminal sends to the whole process group, so ctrl-c doesn't take it down too.
+	watchdog := exec.Command("sh", "-c", `trap '' INT TERM HUP; while kill -0 "$1" 2>/dev/null; do sleep 1; done; "$2" stop -D "$3" -m immediate; rm -rf "$4"`,
+		"watchdog", strconv.Itoa(os.Getpid()), pgCtl, data, dir)
+	if err := watchdog.Start(); err != nil {
+		log.Printf("unable to start local postgres watchdog, it may be left running if this process is killed: %v", err)
+	}
+
+	stop := func() {
+		if watchdog.Process != nil {
+			_ = watchdog.Process.Kill()
+			_ = watchdog.Wait()
+		}
+		if err := runQuiet(context.Background(), pgCtl, "stop", "-D", data, "-m", "fast"); err != nil {
+			log.Printf("unable to stop local postgres: %v", err)
+		}
+		_ = os.RemoveAll(dir)
+	}
+
+	admin := fmt.Sprintf("user=localuser password=supersecret dbname=postgres sslmode=disable host=127.0.0.1 port=%d", port)
+	if err := waitForLocalPostgres(ctx, admin, opts.readiness); err != nil {
+		if logs, readErr := os.ReadFile(logFile); readErr == nil {
+			log.Printf("local postgres log:\n%s", logs)
+		}
+		stop()
+		return "", nil, err
+	}
+	if err := createLocalDatabase(ctx, admin, "testdb"); err != nil {
+		stop()
+		return "", nil, err
+	}
+
+	log.Printf("local postgres running on port %d in %s", port, dir)
+	dsn, err := withDatabaseName(admin, "testdb")
+	if err != nil {
+		stop()
+		return "", nil, err
+	}
+	return dsn, stop, nil
+}
+
+// postgresBinary finds a postgres program in bin, or on PATH when bin is empty
+func postgresBinary(bin, name string) (string, error) {
+	if bin != "" {
+		return filepath.Join(bin, name), nil
+	}
+	found, err := exec.LookPath(name)
+	if err != nil {
+		return "", fmt.Errorf("%s is not on PATH, install postgres or set -local-postgres-bin: %w", name, err)
+	}
+	return found, nil
+}
+
+// freePort returns a tcp port nothing is listening on right now
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = l.Close() }()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// waitForLocalPostgres pings until the server answers
+func waitForLocalPostgres(ctx context.Context, dsn string, cfg readiness.Config) error {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return err
+	}
+	defer safeClose(db)
+	if err := readiness.Wait(ctx, cfg, db.PingContext); err != nil {
+		return fmt.Errorf("local postgres: %w", err)
+	}
+	return nil
+}
+
+func createLocalDatabase(ctx context.Context, dsn, name string) error {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return err
+	}
+	defer safeClose(db)
+	_, err = db.ExecContext(ctx, "create database "+pgx.Identifier{name}.Sanitize())
+	return err
+}
+
+// runQuiet runs a program, only printing its output when it fails
+func runQuiet(ctx context.Context, name string, args ...string) error {
+	output, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w\n%s", err, output)
+	}
+	return nil
+}
@@ -5,24 +5,20 @@ import (
 	"database/sql"
 	"embed"
 	"encoding/json"
+	"flag"
 	"fmt"
-	"github.com/jmoiron/sqlx"
 	"github.com/pressly/goose/v3"
 	"github.com/volatiletech/null/v8"
 	"github.com/volatiletech/sqlboiler/v4/boil"
+	gomigrations "go-orm-test/migrations"
+	"go-orm-test/readiness"
 	"go-orm-test/sqlbdb"
 	"go-orm-test/sqlcdb"
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"gorm.io/gorm/schema"
 	"io"
-	"io/fs"
 	"log"
-	"strings"
+	"os"
 	"time"
-
-	//_ "github.com/lib/pq"
-	"github.com/jackc/pgx/v5"
 )
 
 // TODO https://github.com/stytchauth/sqx
@@ -38,6 +34,7 @@ type CustomSample struct {
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 	DeletedAt   *time.Time
+	Slug        *string
 }
 
 // SqlxSample to be used with sqlx
@@ -49,6 +46,7 @@ type SqlxSample struct {
 	CreatedAt   time.Time  `db:"created_at"`
 	UpdatedAt   time.Time  `db:"updated_at"`
 	DeletedAt   *time.Time `db:"deleted_at"`
+	Slug        *string    `db:"slug"`
 }
 
 // SampleTable to be used with gorm - must be named after the table
@@ -61,54 +59,118 @@ type SampleTable struct {
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 	DeletedAt   *time.Time
+	Slug        *string
 }
 
-// before running, run `docker-compose -f postgres.yml up`
-// note, error handling is not done here for ease of comparison
+const (
+	driverName              = "pgx"
+	defaultConnectionString = "user=localuser password=supersecret dbname=testdb sslmode=disable host=localhost port=5433"
+)
+
+// connectionString is the database every command connects to by default, it points at the local postgres when
+// -local-postgres is set
+var connectionString = defaultConnectionString
+
+// before running, run `docker-compose -f postgres.yml up`, or pass -local-postgres
 func main() {
-	driverName := "pgx"
-	connectionString := "user=localuser password=supersecret dbname=testdb sslmode=disable host=localhost port=5433"
 	ctx := context.Background() // you don't need to use contexts, but it's good practice
 
-	////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
-	// make connections
-	////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
-	// custom/sqlboiler connection
-	customDBConnection, err := sql.Open(driverName, connectionString)
-	if err != nil {
-		panic(err)
+	flag.StringVar(&migrations.env, "env", envOr("PLAYGROUND_ENV", "default"), "environment used to pick the migrations config rules and the pool config")
+	flag.StringVar(&migrations.configPath, "migrations-config", "migrations.json", "migrations config file")
+	poolConfigPath := flag.String("pool-config", "pool.json", "connection pool config file, the library defaults are used when it doesn't exist")
+	flag.Var(&migrations.include, "include-migrations", "only apply migrations matching this rule (glob:<pattern> or tag:<name>), can be repeated")
+	flag.Var(&migrations.exclude, "exclude-migrations", "skip migrations matching this rule (glob:<pattern> or tag:<name>), can be repeated")
+	flag.StringVar(&migrations.seeds, "seeds", "", "comma separated seed datasets to apply after migrating, instead of the ones configured for the environment")
+	localPostgres := flag.Bool("local-postgres", false, "start a throwaway postgres with initdb and pg_ctl instead of using the docker-compose one")
+	local := localPostgresOptions{readiness: waitingForDB}
+	flag.StringVar(&local.bin, "local-postgres-bin", "", "directory holding initdb and pg_ctl, looked up on PATH when empty")
+	flag.DurationVar(&local.readiness.Initial, "local-postgres-backoff", waitingForDB.Initial, "first wait between local postgres readiness checks, doubled after every failure")
+	flag.DurationVar(&local.readiness.Max, "local-postgres-max-backoff", waitingForDB.Max, "longest wait between local postgres readiness checks")
+	flag.DurationVar(&local.readiness.Timeout, "local-postgres-timeout", waitingForDB.Timeout, "how long to wait for the local postgres to accept connections")
+	flag.Parse()
+
+	var err error
+	if pools, err = loadPoolConfig(*poolConfigPath, migrations.env); err != nil {
+		log.Fatalf("unable to read pool config: %v", err)
 	}
-	defer safeClose(customDBConnection)
 
-	// sqlx connection
-	sqlxDBConnection, err := sqlx.ConnectContext(ctx, driverName, connectionString)
-	if err != nil {
-		panic(err)
+	command, args := "samples", []string(nil)
+	if flag.NArg() > 0 {
+		command, args = flag.Arg(0), flag.Args()[1:]
 	}
-	defer safeClose(sqlxDBConnection)
 
-	// gorm connection
-	gormDBConnection, err := gorm.Open(postgres.Open(connectionString), &gorm.Config{
-		NamingStrategy: schema.NamingStrategy{
-			TablePrefix:   "test.",
-			SingularTable: true,
-		},
-	})
+	if !*localPostgres {
+		os.Exit(runCommand(ctx, command, args))
+	}
+	dsn, stop, err := startLocalPostgres(ctx, local)
 	if err != nil {
-		panic(err)
+		log.Fatalf("unable to start local postgres: %v", err)
 	}
-	defer func() {
-		db, _ := gormDBConnection.DB()
-		_ = db.Close()
-	}()
+	connectionString = dsn
+	code := runCommand(ctx, command, args)
+	stop()
+	os.Exit(code)
+}
+
+// runCommand runs the named command and returns the exit code
+func runCommand(ctx context.Context, command string, args []string) int {
+	switch command {
+	case "contention":
+		return runContention(ctx, args)
+	case "faults":
+		return runFaults(ctx, args)
+	case "generate":
+		return runGenerate(ctx, args)
+	case "migrate":
+		return runMigrate(ctx, args)
+	case "proxy":
+		return runProxy(ctx, args)
+	case "samples":
+		runSamples(ctx, connectionString)
+		return 0
+	case "cancel":
+		return runCancel(ctx, args)
+	case "capture":
+		return runCapture(ctx, args)
+	case "compare":
+		return runCompare(ctx, args)
+	case "load":
+		return runLoad(ctx, args)
+	case "pool":
+		return runPool(ctx, args)
+	case "schema":
+		return runSchemaDrift(ctx, args)
+	case "sqlite":
+		return runSQLite(ctx, args)
+	case "mysql":
+		return runMySQL(ctx, args)
+	case "tz":
+		return runTimezoneMatrix(ctx, args)
+	case "verify":
+		return runVerify(ctx, args)
+	default:
+		log.Printf("unknown command %q", command)
+		return 2
+	}
+}
 
-	// sqlc connection
-	sqlcDBConnection, err := pgx.Connect(ctx, connectionString)
+// runSamples runs the same inserts and selects with every library and prints the results
+// note, error handling is not done here for ease of comparison
+func runSamples(ctx context.Context, connectionString string) {
+
+	////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+	// make connections
+	////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+	conns, err := openConnections(ctx, connectionString)
 	if err != nil {
 		panic(err)
 	}
-	defer safeCloseCtx(sqlcDBConnection)
-	sqlcQueries := sqlcdb.New(sqlcDBConnection)
+	defer conns.Close()
+	customDBConnection := conns.custom
+	sqlxDBConnection := conns.sqlx
+	gormDBConnection := conns.gorm
+	sqlbDBConnection := conns.sqlboiler
+	sqlcQueries := conns.sqlcQueries
 
 	////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 	// do migrations with goose
@@ -158,7 +220,7 @@ func main() {
 		Description: null.StringFrom("SQLBoiler inserted description"),
 		IntExample:  null.IntFrom(3),
 	}
-	_ = sqlbdbSample.Insert(ctx, customDBConnection, boil.Infer())
+	_ = sqlbdbSample.Insert(ctx, sqlbDBConnection, boil.Infer())
 
 	////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 	// test selects
@@ -169,28 +231,28 @@ func main() {
 	customSamples := make([]CustomSample, 0)
 	for rows.Next() {
 		var c CustomSample
-		_ = rows.Scan(&c.ID, &c.Name, &c.Description, &c.IntExample, &c.CreatedAt, &c.UpdatedAt, &c.DeletedAt)
+		_ = rows.Scan(&c.ID, &c.Name, &c.Description, &c.IntExample, &c.CreatedAt, &c.UpdatedAt, &c.DeletedAt, &c.Slug)
 		customSamples = append(customSamples, c)
 	}
-	printSamples("custom", customSamples)
+	printSamples("custom", mapSamples(customSamples, sampleFromCustom))
 
 	// sqlx select
 	sqlxSamples := make([]SqlxSample, 0)
 	_ = sqlxDBConnection.SelectContext(ctx, &sqlxSamples, "select * from test.sample_table")
-	printSamples("sqlx", sqlxSamples)
+	printSamples("sqlx", mapSamples(sqlxSamples, sampleFromSqlx))
 
 	// gorm select
 	gormSamples := make([]SampleTable, 0)
 	_ = gormDBConnection.Find(&gormSamples)
-	printSamples("gorm", gormSamples)
+	printSamples("gorm", mapSamples(gormSamples, sampleFromGorm))
 
 	// sqlc select
 	sqlcSamples, _ := sqlcQueries.GetAllSamples(ctx)
-	printSamples("sqlc", sqlcSamples)
+	printSamples("sqlc", mapSamples(sqlcSamples, sampleFromSqlc))
 
 	// sqlboilere insert
-	sqlbSamples, _ := sqlbdb.SampleTables().All(ctx, customDBConnection)
-	printSamples("sqlboiler", sqlbSamples)
+	sqlbSamples, _ := sqlbdb.SampleTables().All(ctx, sqlbDBConnection)
+	printSamples("sqlboiler", mapSamples(sqlbSamples, sampleFromSqlboiler))
 
 	////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 	// test inserts with returned
@@ -206,7 +268,7 @@ func main() {
 		cs.Name, cs.Description, cs.IntExample,
 	)
 	_ = row.Scan(&cs.ID, &cs.CreatedAt, &cs.UpdatedAt)
-	printSamples("inserted custom", cs)
+	printSamples("inserted custom", sampleFromCustom(cs))
 
 	// sqlx insert
 	ss := SqlxSample{
@@ -220,7 +282,7 @@ func main() {
 		ss,
 	)
 	_ = sqlxDBConnection.GetContext(ctx, &ss, query, args...)
-	printSamples("sqlx inserted", ss)
+	printSamples("sqlx inserted", sampleFromSqlx(ss))
 
 	// gorm insert
 	st := SampleTable{
@@ -228,7 +290,7 @@ func main() {
 		Description: ptr("Gorm inserted description"),
 	}
 	_ = gormDBConnection.Create(&st)
-	printSamples("gorm inserted", st)
+	printSamples("gorm inserted", sampleFromGorm(st))
 
 	// sqlc insert
 	sc, _ := sqlcQueries.CreateSampleWithReturn(ctx, sqlcdb.CreateSampleWithReturnParams{
@@ -236,7 +298,7 @@ func main() {
 		Description: ptr("SQLC inserted description"),
 		IntExample:  ptr(int32(3)),
 	})
-	printSamples("sqlc inserted", sc)
+	printSamples("sqlc inserted", sampleFromSqlc(sc))
 
 	// sqlboiler (handles it automatically)
 	sqlbdbSample2 := sqlbdb.SampleTable{
@@ -244,47 +306,65 @@ func main() {
 		Description: null.StringFrom("SQLBoiler inserted description"),
 		IntExample:  null.IntFrom(3),
 	}
-	_ = sqlbdbSample2.Insert(ctx, customDBConnection, boil.Infer())
-	printSamples("sqlboiler inserted", sqlbdbSample2)
+	_ = sqlbdbSample2.Insert(ctx, sqlbDBConnection, boil.Infer())
+	printSamples("sqlboiler inserted", sampleFromSqlboiler(&sqlbdbSample2))
 }
 
-func printSamples(source string, samples any) {
+// printSamples prints canonical samples as json so every library's output has the same shape
+func printSamples[T Sample | []Sample](source string, samples T) {
 	fmt.Printf("Samples from %s:\n", source)
 	b, _ := json.MarshalIndent(samples, "", "  ")
 	fmt.Println(string(b))
 }
 
+// migrateWithGoose waits for db to accept connections, then migrates and seeds it, panicking if any of it fails
 func migrateWithGoose(db *sql.DB) {
 
-	// try to connect a few times - this is mainly for docker-compose
-	var err error
-	for i := 0; i < 3; i++ {
-		if err = db.Ping(); err == nil {
-			break
-		}
-		log.Println("Waiting one second for db to start...")
-		time.Sleep(time.Second)
-	}
-	if err != nil {
+	// wait for the db to accept connections - this is mainly for docker-compose
+	if err := readiness.Wait(context.Background(), waitingForDB, db.PingContext); err != nil {
 		_ = db.Close()
 		panic(fmt.Errorf("unable to ping db: %w", err))
 	}
 	log.Println("DB connection successful")
 
-	// do migrations
-	_ = goose.SetDialect("postgres")
-	goose.SetBaseFS(FilteredFS{
-		FS: embedMigrations,
-		ShouldSkip: func(f fs.DirEntry) bool {
-			return f.Name() == "2_init.sql"
-		},
-	})
-
-	if err := goose.Up(db, "migrations", goose.WithAllowMissing()); err != nil {
+	if err := migrateAndSeed(context.Background(), db); err != nil {
 		panic(err)
 	}
 }
 
+// migrateAndSeed applies the migrations of the configured environment to db, then its seed datasets
+func migrateAndSeed(ctx context.Context, db *sql.DB) error {
+	if err := setMigrationSource(db); err != nil {
+		return err
+	}
+	if err := goose.Up(db, migrationsDir, goose.WithAllowMissing()); err != nil {
+		return err
+	}
+	return applyConfiguredSeeds(ctx, db)
+}
+
+// applyConfiguredSeeds applies the seed datasets of the configured environment, or the ones given with -seeds. Seed
+// data is kept out of the migrations so each environment can pick its own.
+func applyConfiguredSeeds(ctx context.Context, db *sql.DB) error {
+	datasets, err := seedDatasetsFor(migrations)
+	if err != nil {
+		return err
+	}
+	return applySeeds(ctx, db, datasets)
+}
+
+// setMigrationSource points goose at the embedded migrations allowed in the configured environment, and the go
+// migrations at db, the database being migrated
+func setMigrationSource(db *sql.DB) error {
+	filter, err := loadMigrationFilter(migrations)
+	if err != nil {
+		return err
+	}
+	_ = goose.SetDialect("postgres")
+	goose.SetBaseFS(migrationSource{FS: embedMigrations, filter: filter})
+	return gomigrations.SetDB(db, "postgres")
+}
+
 // ptr helper function to convert any literal to a pointer
 func ptr[T any](t T) *T {
 	return &t
@@ -295,39 +375,13 @@ func safeClose(closer io.Closer) {
 	_ = closer.Close()
 }
 
-func safeCloseCtx(closer interface {
-	Close(ctx2 context.Context) error
-}) {
-	_ = closer.Close(context.Background())
+// envOr returns the environment variable, or fallback when it is not set
+func envOr(key, fallback string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return fallback
 }
 
 //go:embed migrations/*.sql
 var embedMigrations embed.FS
-
-type FilteredFS struct {
-	embed.FS
-	ShouldSkip func(f fs.DirEntry) bool
-}
-
-func (f FilteredFS) ReadDir(name string) ([]fs.DirEntry, error) {
-	unfiltered, err := f.FS.ReadDir(name)
-	if err != nil {
-		return unfiltered, err
-	}
-	filtered := make([]fs.DirEntry, 0, len(unfiltered))
-	for _, entry := range unfiltered {
-		if !f.ShouldSkip(entry) {
-			filtered = append(filtered, entry)
-		}
-	}
-	return filtered, err
-}
-
-func init() {
-	goose.SetBaseFS(FilteredFS{
-		FS: embedMigrations,
-		ShouldSkip: func(f fs.DirEntry) bool {
-			return strings.Contains(f.Name(), "test_data")
-		},
-	})
-}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"github.com/pressly/goose/v3"
+	"io/fs"
+	"log"
+	"math"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const migrationsDir = "migrations"
+
+const migrateUsage = "usage: migrate <list|status|up|up-to VERSION|down|down-to VERSION|redo|reset|version|roundtrip|lint> [-dsn DSN] [-dry-run]"
+
+// runMigrate runs one of the migrate subcommands against the embedded migrations
+func runMigrate(ctx context.Context, args []string) int {
+	if len(args) == 0 {
+		log.Fatal(migrateUsage)
+	}
+	command, args := args[0], args[1:]
+	switch command {
+	case "list":
+		return runMigrateList(args)
+	case "lint":
+		return runMigrateLint(args)
+	case "roundtrip":
+		return runMigrateRoundTrip(ctx, args)
+	}
+
+	flags := flag.NewFlagSet("migrate "+command, flag.ExitOnError)
+	dsn := flags.String("dsn", connectionString, "connection string")
+	dryRun := flags.Bool("dry-run", false, "print the sql that would be run instead of running it")
+	_ = flags.Parse(args)
+
+	var version int64
+	if command == "up-to" || command == "down-to" {
+		if flags.NArg() != 1 {
+			log.Fatal(migrateUsage)
+		}
+		var err error
+		if version, err = strconv.ParseInt(flags.Arg(0), 10, 64); err != nil {
+			log.Fatalf("invalid version %q: %v", flags.Arg(0), err)
+		}
+	}
+
+	db, err := sql.Open(driverName, *dsn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer safeClose(db)
+	if err := db.Ping(); err != nil {
+		log.Fatalf("unable to ping db: %v", err)
+	}
+	if err := setMigrationSource(db); err != nil {
+		log.Fatal(err)
+	}
+
+	if *dryRun {
+		err = printDryRun(db, command, version)
+	} else {
+		err = runGooseCommand(db, command, version)
+		// seeded like migrateWithGoose does, as up is how an environment is brought up to date
+		if err == nil && (command == "up" || command == "up-to") {
+			err = applyConfiguredSeeds(ctx, db)
+		}
+	}
+	if err != nil {
+		log.Printf("migrate %s: %v", command, err)
+		return 1
+	}
+	return 0
+}
+
+func runGooseCommand(db *sql.DB, command string, version int64) error {
+	switch command {
+	case "status":
+		return printMigrationStatus(db)
+	case "up":
+		return goose.Up(db, migrationsDir, goose.WithAllowMissing())
+	case "up-to":
+		return goose.UpTo(db, migrationsDir, version, goose.WithAllowMissing())
+	case "down":
+		return goose.Down(db, migrationsDir)
+	case "down-to":
+		return goose.DownTo(db, migrationsDir, version)
+	case "redo":
+		return goose.Redo(db, migrationsDir)
+	case "reset":
+		return goose.Reset(db, migrationsDir)
+	case "version":
+		current, err := migratedVersion(db)
+		if err != nil {
+			return err
+		}
+		fmt.Println(current)
+		return nil
+	default:
+		return fmt.Errorf("unknown command, %s", migrateUsage)
+	}
+}
+
+// gooseTableExists reports whether goose's version table is there, goose creates it whenever it looks for it and a
+// dry run must not
+func gooseTableExists(db *sql.DB) (bool, error) {
+	var exists bool
+	err := db.QueryRow("select to_regclass($1) is not null", goose.TableName()).Scan(&exists)
+	return exists, err
+}
+
+// migratedVersion is the version of the database, 0 when nothing has been applied
+func migratedVersion(db *sql.DB) (int64, error) {
+	exists, err := gooseTableExists(db)
+	if err != nil || !exists {
+		return 0, err
+	}
+	return goose.GetDBVersion(db)
+}
+
+// migrationState is whether a migration is applied, from the most recent goose_db_version row for it
+type migrationState struct {
+	migration *goose.Migration
+	applied   bool
+	appliedAt time.Time
+}
+
+// migrationStates returns every embedded migration allowed in the environment with its state in the database. Without
+// goose's version table nothing is applied.
+func migrationStates(db *sql.DB) ([]migrationState, error) {
+	found, err := goose.CollectMigrations(migrationsDir, 0, math.MaxInt64)
+	if err != nil {
+		return nil, err
+	}
+	states := make([]migrationState, 0, len(found))
+	exists, err := gooseTableExists(db)
+	if err != nil {
+		return nil, err
+	} else if !exists {
+		for _, m := range found {
+			states = append(states, migrationState{migration: m})
+		}
+		return states, nil
+	}
+
+	rows, err := db.Query(fmt.Sprintf("select version_id, is_applied, tstamp from %s order by id desc", goose.TableName()))
+	if err != nil {
+		return nil, err
+	}
+	defer safeClose(rows)
+	latest := make(map[int64]migrationState)
+	for rows.Next() {
+		var versionID int64
+		var s migrationState
+		if err := rows.Scan(&versionID, &s.applied, &s.appliedAt); err != nil {
+			return nil, err
+		}
+		if _, ok := latest[versionID]; !ok {
+			latest[versionID] = s
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, m := range found {
+		s := latest[m.Version]
+		s.migration = m
+		states = append(states, s)
+	}
+	return states, nil
+}
+
+// printMigrationStatus prints a table of every migration and whether it is applied
+func printMigrationStatus(db *sql.DB) error {
+	states, err := migrationStates(db)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "VERSION\tMIGRATION\tSTATE\tAPPLIED AT")
+	for _, s := range states {
+		state, appliedAt := "pending", ""
+		if s.applied {
+			state, appliedAt = "applied", s.appliedAt.Format(time.RFC3339)
+		}
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.migration.Version, path.Base(s.migration.Source), state, appliedAt)
+	}
+	return w.Flush()
+}
+
+// printDryRun prints the up or down sections of the migrations the command would run, in the order it would run them,
+// and the seed datasets applied after up
+func printDryRun(db *sql.DB, command string, version int64) error {
+	if command == "status" || command == "version" {
+		return runGooseCommand(db, command, version)
+	}
+
+	states, err := migrationStates(db)
+	if err != nil {
+		return err
+	}
+	applied := make([]*goose.Migration, 0)
+	pending := make([]*goose.Migration, 0)
+	for _, s := range states {
+		if s.applied {
+			applied = append(applied, s.migration)
+		} else {
+			pending = append(pending, s.migration)
+		}
+	}
+	// rollbacks go from the newest migration back
+	sort.Slice(applied, func(i, j int) bool { return applied[i].Version > applied[j].Version })
+
+	type step struct {
+		migration *goose.Migration
+		direction string
+	}
+	steps := make([]step, 0)
+	switch command {
+	case "up", "up-to":
+		for _, m := range pending {
+			if command == "up" || m.Version <= version {
+				steps = append(steps, step{m, "Up"})
+			}
+		}
+	case "down", "redo":
+		if len(applied) == 0 {
+			return fmt.Errorf("no migrations to roll back")
+		}
+		steps = append(steps, step{applied[0], "Down"})
+		if command == "redo" {
+			steps = append(steps, step{applied[0], "Up"})
+		}
+	case "down-to", "reset":
+		for _, m := range applied {
+			if command == "reset" || m.Version > version {
+				steps = append(steps, step{m, "Down"})
+			}
+		}
+	default:
+		return fmt.Errorf("unknown command, %s", migrateUsage)
+	}
+
+	if len(steps) == 0 {
+		fmt.Println("-- nothing to run")
+	}
+	for _, s := range steps {
+		section, err := migrationSection(s.migration.Source, s.direction)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("-- %s %s\n%s\n", path.Base(s.migration.Source), strings.ToLower(s.direction), section)
+	}
+
+	if command == "up" || command == "up-to" {
+		datasets, err := seedDatasetsFor(migrations)
+		if err != nil {
+			return err
+		}
+		if len(datasets) > 0 {
+			fmt.Printf("-- then the seed datasets %s, skipping the files already applied\n", strings.Join(datasets, ", "))
+		}
+	}
+	return nil
+}
+
+// migrationSection returns the sql of the "-- +goose Up" or "-- +goose Down" section of a migration file, go
+// migrations only say so as their sql is built while they run
+func migrationSection(source, direction string) (string, error) {
+	if path.Ext(source) == ".go" {
+		return "-- go migration, see " + path.Join(migrationsDir, path.Base(source)), nil
+	}
+	contents, err := fs.ReadFile(embedMigrations, source)
+	if err != nil {
+		return "", err
+	}
+
+	var section bytes.Buffer
+	inSection := false
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if annotation := strings.TrimSpace(line); strings.HasPrefix(annotation, "-- +goose Up") || strings.HasPrefix(annotation, "-- +goose Down") {
+			inSection = strings.HasPrefix(annotation, "-- +goose "+direction)
+			continue
+		}
+		if inSection {
+			section.WriteString(line)
+			section.WriteString("\n")
+		}
+	}
+	return strings.TrimSpace(section.String()), scanner.Err()
+}
+
+// runMigrateList prints the migration files goose sees in every environment of the migrations config, or only the
+// configured one when -env was given explicitly
+func runMigrateList(_ []string) int {
+	envs := []string{migrations.env}
+	if !flagWasSet("env") {
+		config, err := readMigrationConfig(migrations.configPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		envs = envs[:0]
+		for env := range config.Environments {
+			envs = append(envs, env)
+		}
+		sort.Strings(envs)
+	}
+
+	original := migrations.env
+	defer func() { migrations.env = original }()
+	for _, env := range envs {
+		migrations.env = env
+		if err := setMigrationSource(nil); err != nil {
+			log.Fatal(err)
+		}
+		found, err := goose.CollectMigrations(migrationsDir, 0, math.MaxInt64)
+		if err != nil {
+			log.Fatal(err)
+		}
+		datasets, err := seedDatasetsFor(migrations)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%s:\n", env)
+		for _, m := range found {
+			fmt.Printf("  %s\n", path.Base(m.Source))
+		}
+		fmt.Printf("  seeds: %s\n", strings.Join(datasets, ", "))
+	}
+	return 0
+}
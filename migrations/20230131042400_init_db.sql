@@ -12,9 +12,6 @@ create table test.sample_table
     deleted_at timestamp
 );
 
-insert into test.sample_table (name, description, int_example) values ('first', 'with description', 1);
-insert into test.sample_table (name) values ('just name');
-
 -- +goose Down
 drop table test.sample_table;
 drop schema test;
\ No newline at end of file
@@ -0,0 +1,13 @@
+-- +goose Up
+-- existing values were written by now() with the server default TimeZone (UTC in postgres.yml)
+-- lint:ignore column-type-change sample_table only holds sample rows
+alter table test.sample_table
+    alter column created_at type timestamptz using created_at at time zone 'UTC',
+    alter column updated_at type timestamptz using updated_at at time zone 'UTC',
+    alter column deleted_at type timestamptz using deleted_at at time zone 'UTC';
+
+-- +goose Down
+alter table test.sample_table
+    alter column created_at type timestamp using created_at at time zone 'UTC',
+    alter column updated_at type timestamp using updated_at at time zone 'UTC',
+    alter column deleted_at type timestamp using deleted_at at time zone 'UTC';
@@ -0,0 +1,81 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/volatiletech/null/v8"
+	"github.com/volatiletech/sqlboiler/v4/boil"
+	"github.com/volatiletech/sqlboiler/v4/queries/qm"
+	"go-orm-test/sqlbdb"
+	"strings"
+	"unicode"
+)
+
+// slugBatchSize is how many rows are updated per transaction
+const slugBatchSize = 500
+
+func init() {
+	// sqlbdb is generated for postgres, sqlite accepts its quoting and $n placeholders but mysql doesn't, so the mysql
+	// migrations backfill in sql instead
+	addMigration(outsideTx(backfillSlugUp), inTx(backfillSlugDown), "postgres", "sqlite3")
+}
+
+// backfillSlugUp fills in slug for every row in batches, using sqlbdb so the rows are read and written the same way
+// the application does
+func backfillSlugUp(ctx context.Context, db *sql.DB) error {
+	// the backfill is not the application changing the rows, so updated_at is left alone
+	ctx = boil.SkipTimestamps(ctx)
+
+	for {
+		// sqlbdb.SampleTables leaves out soft deleted rows, those need a slug too
+		var batch sqlbdb.SampleTableSlice
+		err := sqlbdb.NewQuery(
+			qm.From(`"test"."sample_table"`),
+			sqlbdb.SampleTableWhere.Slug.IsNull(),
+			qm.OrderBy(sqlbdb.SampleTableColumns.ID),
+			qm.Limit(slugBatchSize),
+		).Bind(ctx, db, &batch)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		for _, s := range batch {
+			s.Slug = null.StringFrom(slugify(s.Name))
+			if err := s.Update(ctx, tx, boil.Whitelist(sqlbdb.SampleTableColumns.Slug)); err != nil {
+				_ = tx.Rollback()
+				return err
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+}
+
+func backfillSlugDown(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, "update test.sample_table set slug = null")
+	return err
+}
+
+// slugify lower cases the name and replaces every run of characters that are not letters or digits with a dash
+func slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
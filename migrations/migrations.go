@@ -0,0 +1,89 @@
+// Package migrations registers the goose migrations written in go. Their files are numbered in the same sequence as
+// the sql migrations next to them, and goose runs both kinds in that one order. The migration include and exclude
+// rules only apply to the sql files.
+//
+// goose v3.3.1 cannot run a go migration outside a transaction at all, it always begins one and hands it to the
+// migration. A step registered with outsideTx ignores that transaction and runs on another connection from the pool
+// given to SetDB, while goose keeps its own open to write the version row in once the step returns.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/pressly/goose/v3"
+	"log"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// db is the database being migrated, used by the steps that run outside goose's transaction, dialect is its goose
+// dialect
+var (
+	db      *sql.DB
+	dialect = "postgres"
+)
+
+// SetDB sets the database being migrated and its goose dialect, it must be called before goose runs. The steps run
+// outside goose's transaction need a second connection from d while goose holds the first, so a pool limited to one
+// open connection is rejected rather than left to wait for itself forever.
+func SetDB(d *sql.DB, gooseDialect string) error {
+	if d != nil && d.Stats().MaxOpenConnections == 1 {
+		return errors.New("the go migrations need two connections, the pool is limited to one")
+	}
+	db = d
+	dialect = gooseDialect
+	return nil
+}
+
+// step is one direction of a go migration, run either in goose's transaction or outside of it
+type step struct {
+	tx func(ctx context.Context, tx *sql.Tx) error
+	db func(ctx context.Context, db *sql.DB) error
+}
+
+// inTx runs the step in goose's transaction, so it is applied together with the version row or not at all
+func inTx(f func(ctx context.Context, tx *sql.Tx) error) step {
+	return step{tx: f}
+}
+
+// outsideTx runs the step on its own connection, so it can commit in batches instead of holding locks for the whole
+// run. Only the version row is written in goose's transaction, so a step that fails part way must be safe to run
+// again.
+func outsideTx(f func(ctx context.Context, db *sql.DB) error) step {
+	return step{db: f}
+}
+
+// addMigration registers a migration with goose, versioned by the name of the calling file. When dialects are given
+// the migration only runs on those, on any other goose records it as applied without running it, so the sql
+// migrations of that database have to do the equivalent.
+func addMigration(up, down step, dialects ...string) {
+	_, filename, _, _ := runtime.Caller(1)
+	only := func(s step) func(tx *sql.Tx) error {
+		return func(tx *sql.Tx) error {
+			for _, d := range dialects {
+				if d == dialect {
+					return s.run(tx)
+				}
+			}
+			if len(dialects) == 0 {
+				return s.run(tx)
+			}
+			log.Printf("skipping %s on %s, it only runs on %s", filepath.Base(filename), dialect, strings.Join(dialects, ", "))
+			return nil
+		}
+	}
+	goose.AddNamedMigration(filename, only(up), only(down))
+}
+
+func (s step) run(tx *sql.Tx) error {
+	ctx := context.Background()
+	if s.tx != nil {
+		return s.tx(ctx, tx)
+	}
+	if db == nil {
+		return errors.New("migrations.SetDB must be called before running a migration outside goose's transaction")
+	}
+	return s.db(ctx, db)
+}
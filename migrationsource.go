@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// migrationTagsPrefix marks the line in a migration listing its tags, e.g. "-- tags: test_data, demo"
+const migrationTagsPrefix = "-- tags:"
+
+// migrationRule matches migration files, rules are written as "glob:<pattern>" or "tag:<name>", a rule without a
+// prefix is a glob
+type migrationRule interface {
+	matches(name string, tags []string) bool
+	String() string
+}
+
+type globRule string
+
+func (r globRule) matches(name string, _ []string) bool {
+	ok, _ := path.Match(string(r), name)
+	return ok
+}
+
+func (r globRule) String() string { return "glob:" + string(r) }
+
+type tagRule string
+
+func (r tagRule) matches(_ string, tags []string) bool {
+	for _, t := range tags {
+		if t == string(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r tagRule) String() string { return "tag:" + string(r) }
+
+func parseMigrationRule(s string) (migrationRule, error) {
+	kind, value, found := strings.Cut(s, ":")
+	if !found {
+		kind, value = "glob", s
+	}
+	switch kind {
+	case "glob":
+		if _, err := path.Match(value, ""); err != nil {
+			return nil, fmt.Errorf("invalid migration rule %q: %w", s, err)
+		}
+		return globRule(value), nil
+	case "tag":
+		return tagRule(value), nil
+	default:
+		return nil, fmt.Errorf("invalid migration rule %q: unknown kind %q", s, kind)
+	}
+}
+
+// migrationFilter decides which migration files goose sees. A file is seen when it matches any include rule (or
+// there are none) and matches no exclude rule.
+type migrationFilter struct {
+	include []migrationRule
+	exclude []migrationRule
+}
+
+func (f migrationFilter) allows(name string, tags []string) bool {
+	included := len(f.include) == 0
+	for _, r := range f.include {
+		if r.matches(name, tags) {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+	for _, r := range f.exclude {
+		if r.matches(name, tags) {
+			return false
+		}
+	}
+	return true
+}
+
+// migrationRules are the include and exclude rules, and the seed datasets, of one environment in the migrations config
+// file
+type migrationRules struct {
+	Include []string `json:"include"`
+	Exclude []string `json:"exclude"`
+	Seeds   []string `json:"seeds"`
+}
+
+// migrationConfig is the migrations config file, rules are keyed by environment
+type migrationConfig struct {
+	Environments map[string]migrationRules `json:"environments"`
+}
+
+// defaultMigrationConfig is used when there is no config file
+var defaultMigrationConfig = migrationConfig{
+	Environments: map[string]migrationRules{
+		"default": {Exclude: []string{"glob:*test_data*", "tag:test_data"}, Seeds: []string{"minimal"}},
+	},
+}
+
+// migrationSettings are set from the command line in main
+type migrationSettings struct {
+	env        string
+	configPath string
+	include    stringsFlag
+	exclude    stringsFlag
+	seeds      string
+}
+
+var migrations = migrationSettings{}
+
+// loadMigrationFilter builds the filter for the environment from the config file, if there is one, with the rules
+// given on the command line added to it
+func loadMigrationFilter(s migrationSettings) (migrationFilter, error) {
+	config, err := readMigrationConfig(s.configPath)
+	if err != nil {
+		return migrationFilter{}, err
+	}
+	rules, ok := config.Environments[s.env]
+	if !ok {
+		return migrationFilter{}, fmt.Errorf("environment %q is not in the migrations config", s.env)
+	}
+
+	var f migrationFilter
+	for _, r := range append(append([]string(nil), rules.Include...), s.include...) {
+		rule, err := parseMigrationRule(r)
+		if err != nil {
+			return migrationFilter{}, err
+		}
+		f.include = append(f.include, rule)
+	}
+	for _, r := range append(append([]string(nil), rules.Exclude...), s.exclude...) {
+		rule, err := parseMigrationRule(r)
+		if err != nil {
+			return migrationFilter{}, err
+		}
+		f.exclude = append(f.exclude, rule)
+	}
+	return f, nil
+}
+
+// readMigrationConfig reads the migrations config file, falling back to defaultMigrationConfig if it does not exist
+func readMigrationConfig(configPath string) (migrationConfig, error) {
+	b, err := os.ReadFile(configPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return defaultMigrationConfig, nil
+	} else if err != nil {
+		return migrationConfig{}, err
+	}
+	var config migrationConfig
+	if err := json.Unmarshal(b, &config); err != nil {
+		return migrationConfig{}, fmt.Errorf("invalid migrations config %s: %w", configPath, err)
+	}
+	return config, nil
+}
+
+// migrationSource is the file system given to goose, hiding the migration files the filter does not allow
+type migrationSource struct {
+	fs.FS
+	filter migrationFilter
+}
+
+func (m migrationSource) ReadDir(name string) ([]fs.DirEntry, error) {
+	unfiltered, err := fs.ReadDir(m.FS, name)
+	if err != nil {
+		return unfiltered, err
+	}
+	filtered := make([]fs.DirEntry, 0, len(unfiltered))
+	for _, entry := range unfiltered {
+		if entry.IsDir() {
+			filtered = append(filtered, entry)
+			continue
+		}
+		tags, err := migrationTags(m.FS, path.Join(name, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if m.filter.allows(entry.Name(), tags) {
+			filtered = append(filtered, entry)
+		}
+	}
+	return filtered, nil
+}
+
+// migrationTags reads the tags line of a migration file, if it has one. Only the comments leading the file are
+// looked at, so a tags line further down, e.g. in a function body, is not taken for one.
+func migrationTags(fsys fs.FS, name string) ([]string, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer safeClose(f)
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "--") {
+			break
+		}
+		if !strings.HasPrefix(line, migrationTagsPrefix) {
+			continue
+		}
+		tags := make([]string, 0)
+		for _, t := range strings.Split(strings.TrimPrefix(line, migrationTagsPrefix), ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				tags = append(tags, t)
+			}
+		}
+		return tags, nil
+	}
+	return nil, scanner.Err()
+}
+
+// flagWasSet reports whether the named command line flag was given explicitly
+func flagWasSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// stringsFlag is a flag that can be given more than once
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
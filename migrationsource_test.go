@@ -0,0 +1,112 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+// testMigrationFiles cover every way a file can be excluded: by name, by tag, by one of several tags, and a tags line
+// that isn't in the header so doesn't count
+var testMigrationFiles = fstest.MapFS{
+	"migrations/001_init.sql":      {Data: []byte("-- +goose Up\ncreate table t (id int);\n")},
+	"migrations/002_test_data.sql": {Data: []byte("-- +goose Up\ninsert into t values (1);\n")},
+	"migrations/003_tagged.sql":    {Data: []byte("-- +goose Up\n-- tags: test_data\ninsert into t values (2);\n")},
+	"migrations/004_dev_only.sql":  {Data: []byte("-- tags: dev_only, demo\n\n-- +goose Up\ninsert into t values (3);\n")},
+	"migrations/005_late_tags.sql": {Data: []byte("-- +goose Up\ncreate table u (id int);\n-- tags: test_data\n")},
+}
+
+func TestMigrationSourceReadDir(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		include stringsFlag
+		exclude stringsFlag
+		want    []string
+	}{
+		{
+			name: "default",
+			env:  "default",
+			want: []string{"001_init.sql", "004_dev_only.sql", "005_late_tags.sql"},
+		},
+		{
+			name: "test",
+			env:  "test",
+			want: []string{"001_init.sql", "002_test_data.sql", "003_tagged.sql", "004_dev_only.sql", "005_late_tags.sql"},
+		},
+		{
+			name: "prod",
+			env:  "prod",
+			want: []string{"001_init.sql", "005_late_tags.sql"},
+		},
+		{
+			name:    "test including a tag from the command line",
+			env:     "test",
+			include: stringsFlag{"tag:demo"},
+			want:    []string{"004_dev_only.sql"},
+		},
+		{
+			name:    "default excluding a glob from the command line",
+			env:     "default",
+			exclude: stringsFlag{"glob:00[45]_*"},
+			want:    []string{"001_init.sql"},
+		},
+	}
+
+	config, err := readMigrationConfig("migrations.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tested := map[string]bool{}
+	for _, tt := range tests {
+		tested[tt.env] = true
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := loadMigrationFilter(migrationSettings{
+				env:        tt.env,
+				configPath: "migrations.json",
+				include:    tt.include,
+				exclude:    tt.exclude,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			entries, err := migrationSource{FS: testMigrationFiles, filter: filter}.ReadDir("migrations")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([]string, 0, len(entries))
+			for _, e := range entries {
+				got = append(got, e.Name())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("goose sees %v, want %v", got, tt.want)
+			}
+		})
+	}
+	for env := range config.Environments {
+		if !tested[env] {
+			t.Errorf("environment %q in migrations.json has no test", env)
+		}
+	}
+}
+
+func TestMigrationTags(t *testing.T) {
+	tests := []struct {
+		file string
+		want []string
+	}{
+		{"migrations/001_init.sql", nil},
+		{"migrations/003_tagged.sql", []string{"test_data"}},
+		{"migrations/004_dev_only.sql", []string{"dev_only", "demo"}},
+		{"migrations/005_late_tags.sql", nil},
+	}
+	for _, tt := range tests {
+		got, err := migrationTags(testMigrationFiles, tt.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s has tags %v, want %v", tt.file, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"flag"
+	"fmt"
+	sqle "github.com/dolthub/go-mysql-server"
+	"github.com/dolthub/go-mysql-server/memory"
+	"github.com/dolthub/go-mysql-server/server"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/pressly/goose/v3"
+	"github.com/sirupsen/logrus"
+	"github.com/volatiletech/null/v8"
+	"github.com/volatiletech/sqlboiler/v4/boil"
+	gomigrations "go-orm-test/migrations"
+	mysqlsqlb "go-orm-test/mysql/sqlbdb"
+	mysqlsqlc "go-orm-test/mysql/sqlcdb"
+	gormmysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+	"log"
+)
+
+// mysqlMigrationsDir holds the mysql translation of the migrations
+const mysqlMigrationsDir = "mysql/migrations"
+
+//go:embed mysql/migrations/*.sql
+var embedMySQLMigrations embed.FS
+
+// startMySQLServer starts an in-process mysql compatible server keeping everything in memory, with an empty test
+// database, and returns a connection string for it along with a function that stops it
+func startMySQLServer() (string, func(), error) {
+	// the server logs every connection and failed query, which would drown out the report
+	logrus.SetLevel(logrus.ErrorLevel)
+	db := memory.NewDatabase("test")
+	db.EnablePrimaryKeyIndexes()
+	engine := sqle.NewDefault(memory.NewDBProvider(db))
+	s, err := server.NewDefaultServer(server.Config{Protocol: "tcp", Address: "127.0.0.1:0"}, engine)
+	if err != nil {
+		return "", nil, err
+	}
+	go func() { _ = s.Start() }()
+	// datetime columns are scanned into time.Time and read as utc like the other databases
+	dsn := fmt.Sprintf("root@tcp(%s)/test?parseTime=true&loc=UTC", s.Listener.Addr())
+	return dsn, func() { _ = s.Close() }, nil
+}
+
+// openMySQLConnections connects every library to the mysql database in dsn. sqlc has no connection of its own as its
+// mysql code is generated for database/sql, the returned queries use the custom pool.
+func openMySQLConnections(ctx context.Context, dsn string) (*connections, *mysqlsqlc.Queries, error) {
+	c := &connections{}
+	var err error
+	for _, db := range []**sql.DB{&c.custom, &c.sqlboiler} {
+		if *db, err = sql.Open("mysql", dsn); err != nil {
+			c.Close()
+			return nil, nil, err
+		}
+	}
+	if err := c.custom.PingContext(ctx); err != nil {
+		c.Close()
+		return nil, nil, err
+	}
+
+	if c.sqlx, err = sqlx.Open("mysql", dsn); err != nil {
+		c.Close()
+		return nil, nil, err
+	}
+
+	gormDB, err := sql.Open("mysql", dsn)
+	if err != nil {
+		c.Close()
+		return nil, nil, err
+	}
+	c.gorm, err = gorm.Open(gormmysql.New(gormmysql.Config{Conn: gormDB}), &gorm.Config{
+		NamingStrategy: schema.NamingStrategy{
+			TablePrefix:   "test.",
+			SingularTable: true,
+		},
+	})
+	if err != nil {
+		safeClose(gormDB)
+		c.Close()
+		return nil, nil, err
+	}
+
+	return c, mysqlsqlc.New(c.custom), nil
+}
+
+// migrateMySQL applies the mysql migrations to db
+func migrateMySQL(db *sql.DB) error {
+	if err := goose.SetDialect("mysql"); err != nil {
+		return err
+	}
+	goose.SetBaseFS(embedMySQLMigrations)
+	if err := gomigrations.SetDB(db, "mysql"); err != nil {
+		return err
+	}
+	return goose.Up(db, mysqlMigrationsDir)
+}
+
+// mysqlLibraries are the libraries run against mysql. Without returning, every insert returning has to read the row
+// back by the id the insert generated, and custom and sqlx need ? instead of $n placeholders.
+func mysqlLibraries(queries *mysqlsqlc.Queries) []libraryOps {
+	libs := []libraryOps{
+		{
+			library: "custom",
+			note:    "? placeholders, insert returning selects the row by LastInsertId",
+			insert: func(ctx context.Context, c *connections, s Sample) error {
+				_, err := c.custom.ExecContext(ctx,
+					"insert into test.sample_table (name, description, int_example) values (?, ?, ?)",
+					s.Name, s.Description, s.IntExample,
+				)
+				return err
+			},
+			insertReturning: func(ctx context.Context, c *connections, s Sample) (Sample, error) {
+				result, err := c.custom.ExecContext(ctx,
+					"insert into test.sample_table (name, description, int_example) values (?, ?, ?)",
+					s.Name, s.Description, s.IntExample,
+				)
+				if err != nil {
+					return Sample{}, err
+				}
+				id, err := result.LastInsertId()
+				if err != nil {
+					return Sample{}, err
+				}
+				var cs CustomSample
+				err = c.custom.QueryRowContext(ctx, "select * from test.sample_table where id = ?", id).
+					Scan(&cs.ID, &cs.Name, &cs.Description, &cs.IntExample, &cs.CreatedAt, &cs.UpdatedAt, &cs.DeletedAt, &cs.Slug)
+				return sampleFromCustom(cs), err
+			},
+			getByID: func(ctx context.Context, c *connections, id int) (Sample, error) {
+				var cs CustomSample
+				err := c.custom.QueryRowContext(ctx, "select * from test.sample_table where id = ?", id).
+					Scan(&cs.ID, &cs.Name, &cs.Description, &cs.IntExample, &cs.CreatedAt, &cs.UpdatedAt, &cs.DeletedAt, &cs.Slug)
+				return sampleFromCustom(cs), err
+			},
+		},
+		{
+			library: "sqlx",
+			note:    "? placeholders, insert returning selects the row by LastInsertId",
+			insertReturning: func(ctx context.Context, c *connections, s Sample) (Sample, error) {
+				ss := SqlxSample{Name: s.Name, Description: s.Description, IntExample: s.IntExample}
+				result, err := c.sqlx.NamedExecContext(ctx,
+					"insert into test.sample_table (name, description, int_example) values (:name, :description, :int_example)",
+					ss,
+				)
+				if err != nil {
+					return Sample{}, err
+				}
+				id, err := result.LastInsertId()
+				if err != nil {
+					return Sample{}, err
+				}
+				err = c.sqlx.GetContext(ctx, &ss, "select * from test.sample_table where id = ?", id)
+				return sampleFromSqlx(ss), err
+			},
+			getByID: func(ctx context.Context, c *connections, id int) (Sample, error) {
+				var ss SqlxSample
+				err := c.sqlx.GetContext(ctx, &ss, "select * from test.sample_table where id = ?", id)
+				return sampleFromSqlx(ss), err
+			},
+		},
+		{
+			library: "sqlc",
+			note:    "generated from mysql/query.sql, CreateSampleWithReturn only returns the id",
+			insert: func(ctx context.Context, _ *connections, s Sample) error {
+				return queries.CreateSampleNoReturn(ctx, mysqlsqlc.CreateSampleNoReturnParams{
+					Name:        s.Name,
+					Description: nullString(s.Description),
+					IntExample:  nullInt32(s.IntExample),
+				})
+			},
+			insertReturning: func(ctx context.Context, _ *connections, s Sample) (Sample, error) {
+				id, err := queries.CreateSampleWithReturn(ctx, mysqlsqlc.CreateSampleWithReturnParams{
+					Name:        s.Name,
+					Description: nullString(s.Description),
+					IntExample:  nullInt32(s.IntExample),
+				})
+				if err != nil {
+					return Sample{}, err
+				}
+				sc, err := queries.GetSampleByID(ctx, int32(id))
+				return sampleFromMySQLSqlc(sc), err
+			},
+			getByID: func(ctx context.Context, _ *connections, id int) (Sample, error) {
+				sc, err := queries.GetSampleByID(ctx, int32(id))
+				return sampleFromMySQLSqlc(sc), err
+			},
+			listAll: func(ctx context.Context, _ *connections) ([]Sample, error) {
+				sqlcSamples, err := queries.GetAllSamples(ctx)
+				return mapSamples(sqlcSamples, sampleFromMySQLSqlc), err
+			},
+		},
+		{
+			library: "sqlboiler",
+			note:    "generated from mysql/schema.sql, Insert selects the row back itself",
+			insert: func(ctx context.Context, c *connections, s Sample) error {
+				sb := mysqlSqlboilerSample(s)
+				return sb.Insert(ctx, c.sqlboiler, boil.Infer())
+			},
+			insertReturning: func(ctx context.Context, c *connections, s Sample) (Sample, error) {
+				sb := mysqlSqlboilerSample(s)
+				err := sb.Insert(ctx, c.sqlboiler, boil.Infer())
+				return sampleFromMySQLSqlboiler(sb), err
+			},
+			getByID: func(ctx context.Context, c *connections, id int) (Sample, error) {
+				sb, err := mysqlsqlb.FindSampleTable(ctx, c.sqlboiler, id)
+				if err != nil {
+					return Sample{}, err
+				}
+				return sampleFromMySQLSqlboiler(sb), nil
+			},
+			listAll: func(ctx context.Context, c *connections) ([]Sample, error) {
+				sqlbSamples, err := mysqlsqlb.SampleTables().All(ctx, c.sqlboiler)
+				return mapSamples(sqlbSamples, sampleFromMySQLSqlboiler), err
+			},
+		},
+	}
+
+	// what isn't replaced above runs the postgres code, gorm entirely as its dialector handles the differences
+	ops := make([]libraryOps, 0, len(libraries))
+	for _, l := range libraries {
+		m := libraryOps{library: l.library, note: "same code as postgres"}
+		for _, lib := range libs {
+			if lib.library == l.library {
+				m = lib
+			}
+		}
+		if m.insert == nil {
+			m.insert = l.insert
+		}
+		if m.insertReturning == nil {
+			m.insertReturning = l.insertReturning
+		}
+		if m.getByID == nil {
+			m.getByID = l.getByID
+		}
+		if m.listAll == nil {
+			m.listAll = l.listAll
+		}
+		ops = append(ops, m)
+	}
+	return ops
+}
+
+func mysqlSqlboilerSample(s Sample) *mysqlsqlb.SampleTable {
+	return &mysqlsqlb.SampleTable{
+		Name:        s.Name,
+		Description: null.StringFromPtr(s.Description),
+		IntExample:  null.IntFromPtr(s.IntExample),
+	}
+}
+
+func nullInt32(i *int) sql.NullInt32 {
+	if i == nil {
+		return sql.NullInt32{}
+	}
+	return sql.NullInt32{Int32: int32(*i), Valid: true}
+}
+
+// runMySQL runs every scenario with every library against an in-process mysql compatible server, then reports which
+// operations failed and where the libraries' results differ. It returns 1 if any failed or differ.
+func runMySQL(ctx context.Context, args []string) int {
+	flags := flag.NewFlagSet("mysql", flag.ExitOnError)
+	report := portabilityFlags(flags)
+	_ = flags.Parse(args)
+
+	dsn, stop, err := startMySQLServer()
+	if err != nil {
+		log.Fatalf("unable to start mysql server: %v", err)
+	}
+	defer stop()
+
+	conns, queries, err := openMySQLConnections(ctx, dsn)
+	if err != nil {
+		log.Fatalf("unable to connect to mysql: %v", err)
+	}
+	defer conns.Close()
+	if err := migrateMySQL(conns.custom); err != nil {
+		log.Fatalf("unable to migrate mysql: %v", err)
+	}
+
+	return runPortability(ctx, conns, mysqlLibraries(queries), report)
+}
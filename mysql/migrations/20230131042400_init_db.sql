@@ -0,0 +1,14 @@
+-- +goose Up
+create table test.sample_table
+(
+    id          int         not null auto_increment primary key,
+    name        text        not null,
+    description text,
+    int_example int,
+    created_at  datetime(6) not null default current_timestamp(6),
+    updated_at  datetime(6) not null default current_timestamp(6),
+    deleted_at  datetime(6)
+);
+
+-- +goose Down
+drop table test.sample_table;
@@ -0,0 +1,10 @@
+-- +goose Up
+alter table test.sample_table add column slug text;
+
+-- the go migration backfilling slug only runs on postgres and sqlite, this does the same in sql
+update test.sample_table
+set slug = trim(both '-' from regexp_replace(lower(name), '[^a-z0-9]+', '-'))
+where slug is null;
+
+-- +goose Down
+alter table test.sample_table drop column slug;
@@ -0,0 +1,23 @@
+
+-- name: CreateSampleNoReturn :exec
+insert into sample_table (name, description, int_example)
+values (?, ?, ?);
+
+-- name: GetAllSamples :many
+select * from sample_table;
+
+-- mysql has no returning, so CreateSampleWithReturn only returns the generated id and callers read the row back with
+-- GetSampleByID
+
+-- name: CreateSampleWithReturn :execlastid
+insert into sample_table (name, description, int_example)
+values (?, ?, ?);
+
+-- name: GetDescriptions :many
+select description from sample_table;
+
+-- name: GetIdDescriptions :many
+select id, description from sample_table;
+
+-- name: GetSampleByID :one
+select * from sample_table where id = ?;
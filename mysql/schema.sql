@@ -0,0 +1,15 @@
+-- The schema sqlc and sqlboiler generate the mysql packages from, kept in step with mysql/migrations by hand.
+-- The postgres test schema is the test database in mysql, the generated code refers to the table unqualified.
+-- Timestamps are datetime rather than timestamp, mysql converts timestamp columns to and from the session time zone.
+
+create table sample_table
+(
+    id          int         not null auto_increment primary key,
+    name        text        not null,
+    description text,
+    int_example int,
+    created_at  datetime(6) not null default current_timestamp(6),
+    updated_at  datetime(6) not null default current_timestamp(6),
+    deleted_at  datetime(6),
+    slug        text
+);
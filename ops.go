@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"github.com/jackc/pgx/v5"
+	"github.com/jmoiron/sqlx"
+	"github.com/volatiletech/null/v8"
+	"github.com/volatiletech/sqlboiler/v4/boil"
+	"github.com/volatiletech/sqlboiler/v4/queries"
+	"go-orm-test/sqlbdb"
+	"go-orm-test/sqlcdb"
+	"go-orm-test/txretry"
+	"gorm.io/gorm"
+	"time"
+)
+
+// libraryOps are the operations every library implements, so the same scenario can be run with each of them and the
+// results compared as canonical samples
+type libraryOps struct {
+	library string
+	// note says how the library's code differs from the postgres one, for the other databases
+	note            string
+	insert          func(ctx context.Context, c *connections, s Sample) error
+	insertReturning func(ctx context.Context, c *connections, s Sample) (Sample, error)
+	getByID         func(ctx context.Context, c *connections, id int) (Sample, error)
+	updateName      func(ctx context.Context, c *connections, id int, name string) error
+	listAll         func(ctx context.Context, c *connections) ([]Sample, error)
+	// sleep runs pg_sleep for d, to have a query still running when a context is canceled or a timeout hits
+	sleep func(ctx context.Context, c *connections, d time.Duration) error
+	// increment adds one to int_example of the row in a serializable transaction, retried with p
+	increment func(ctx context.Context, c *connections, id int, p txretry.Policy) error
+}
+
+var libraries = []libraryOps{
+	{
+		library: "custom",
+		insert: func(ctx context.Context, c *connections, s Sample) error {
+			_, err := c.custom.ExecContext(ctx,
+				"insert into test.sample_table (name, description, int_example) values ($1, $2, $3)",
+				s.Name, s.Description, s.IntExample,
+			)
+			return err
+		},
+		insertReturning: func(ctx context.Context, c *connections, s Sample) (Sample, error) {
+			var cs CustomSample
+			err := c.custom.QueryRowContext(ctx,
+				"insert into test.sample_table (name, description, int_example) values ($1, $2, $3) returning *",
+				s.Name, s.Description, s.IntExample,
+			).Scan(&cs.ID, &cs.Name, &cs.Description, &cs.IntExample, &cs.CreatedAt, &cs.UpdatedAt, &cs.DeletedAt, &cs.Slug)
+			return sampleFromCustom(cs), err
+		},
+		getByID: func(ctx context.Context, c *connections, id int) (Sample, error) {
+			var cs CustomSample
+			err := c.custom.QueryRowContext(ctx, "select * from test.sample_table where id = $1", id).
+				Scan(&cs.ID, &cs.Name, &cs.Description, &cs.IntExample, &cs.CreatedAt, &cs.UpdatedAt, &cs.DeletedAt, &cs.Slug)
+			return sampleFromCustom(cs), err
+		},
+		updateName: func(ctx context.Context, c *connections, id int, name string) error {
+			_, err := c.custom.ExecContext(ctx, "update test.sample_table set name = $1, updated_at = now() where id = $2", name, id)
+			return err
+		},
+		sleep: func(ctx context.Context, c *connections, d time.Duration) error {
+			_, err := c.custom.ExecContext(ctx, "select pg_sleep($1)", d.Seconds())
+			return err
+		},
+		increment: func(ctx context.Context, c *connections, id int, p txretry.Policy) error {
+			return txretry.SQL(ctx, c.custom, p, func(tx *sql.Tx) error {
+				// a null int_example counts as 0, like every other library
+				var value sql.NullInt64
+				if err := tx.QueryRowContext(ctx, "select int_example from test.sample_table where id = $1", id).Scan(&value); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx, "update test.sample_table set int_example = $1 where id = $2", value.Int64+1, id)
+				return err
+			})
+		},
+		listAll: func(ctx context.Context, c *connections) ([]Sample, error) {
+			rows, err := c.custom.QueryContext(ctx, "select * from test.sample_table")
+			if err != nil {
+				return nil, err
+			}
+			defer safeClose(rows)
+			customSamples := make([]CustomSample, 0)
+			for rows.Next() {
+				var cs CustomSample
+				if err := rows.Scan(&cs.ID, &cs.Name, &cs.Description, &cs.IntExample, &cs.CreatedAt, &cs.UpdatedAt, &cs.DeletedAt, &cs.Slug); err != nil {
+					return nil, err
+				}
+				customSamples = append(customSamples, cs)
+			}
+			return mapSamples(customSamples, sampleFromCustom), rows.Err()
+		},
+	},
+	{
+		library: "sqlx",
+		insert: func(ctx context.Context, c *connections, s Sample) error {
+			_, err := c.sqlx.NamedExecContext(ctx,
+				"insert into test.sample_table (name, description, int_example) values (:name, :description, :int_example)",
+				SqlxSample{Name: s.Name, Description: s.Description, IntExample: s.IntExample},
+			)
+			return err
+		},
+		insertReturning: func(ctx context.Context, c *connections, s Sample) (Sample, error) {
+			ss := SqlxSample{Name: s.Name, Description: s.Description, IntExample: s.IntExample}
+			query, args, err := c.sqlx.BindNamed(
+				"insert into test.sample_table (name, description, int_example) values (:name, :description, :int_example) returning *",
+				ss,
+			)
+			if err != nil {
+				return Sample{}, err
+			}
+			err = c.sqlx.GetContext(ctx, &ss, query, args...)
+			return sampleFromSqlx(ss), err
+		},
+		getByID: func(ctx context.Context, c *connections, id int) (Sample, error) {
+			var ss SqlxSample
+			err := c.sqlx.GetContext(ctx, &ss, "select * from test.sample_table where id = $1", id)
+			return sampleFromSqlx(ss), err
+		},
+		updateName: func(ctx context.Context, c *connections, id int, name string) error {
+			_, err := c.sqlx.ExecContext(ctx, "update test.sample_table set name = $1, updated_at = now() where id = $2", name, id)
+			return err
+		},
+		sleep: func(ctx context.Context, c *connections, d time.Duration) error {
+			_, err := c.sqlx.ExecContext(ctx, "select pg_sleep($1)", d.Seconds())
+			return err
+		},
+		increment: func(ctx context.Context, c *connections, id int, p txretry.Policy) error {
+			return txretry.Sqlx(ctx, c.sqlx, p, func(tx *sqlx.Tx) error {
+				var value sql.NullInt64
+				if err := tx.GetContext(ctx, &value, "select int_example from test.sample_table where id = $1", id); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx, "update test.sample_table set int_example = $1 where id = $2", value.Int64+1, id)
+				return err
+			})
+		},
+		listAll: func(ctx context.Context, c *connections) ([]Sample, error) {
+			sqlxSamples := make([]SqlxSample, 0)
+			err := c.sqlx.SelectContext(ctx, &sqlxSamples, "select * from test.sample_table")
+			return mapSamples(sqlxSamples, sampleFromSqlx), err
+		},
+	},
+	{
+		library: "gorm",
+		insert: func(ctx context.Context, c *connections, s Sample) error {
+			return c.gorm.WithContext(ctx).Create(&SampleTable{Name: s.Name, Description: s.Description, IntExample: s.IntExample}).Error
+		},
+		insertReturning: func(ctx context.Context, c *connections, s Sample) (Sample, error) {
+			st := SampleTable{Name: s.Name, Description: s.Description, IntExample: s.IntExample}
+			err := c.gorm.WithContext(ctx).Create(&st).Error
+			return sampleFromGorm(st), err
+		},
+		getByID: func(ctx context.Context, c *connections, id int) (Sample, error) {
+			var st SampleTable
+			err := c.gorm.WithContext(ctx).First(&st, id).Error
+			return sampleFromGorm(st), err
+		},
+		updateName: func(ctx context.Context, c *connections, id int, name string) error {
+			// gorm sets updated_at itself
+			return c.gorm.WithContext(ctx).Model(&SampleTable{}).Where("id = ?", id).Update("name", name).Error
+		},
+		sleep: func(ctx context.Context, c *connections, d time.Duration) error {
+			return c.gorm.WithContext(ctx).Exec("select pg_sleep(?)", d.Seconds()).Error
+		},
+		increment: func(ctx context.Context, c *connections, id int, p txretry.Policy) error {
+			return txretry.Gorm(ctx, c.gorm, p, func(tx *gorm.DB) error {
+				var st SampleTable
+				if err := tx.First(&st, id).Error; err != nil {
+					return err
+				}
+				value := 0
+				if st.IntExample != nil {
+					value = *st.IntExample
+				}
+				return tx.Model(&SampleTable{}).Where("id = ?", id).Update("int_example", value+1).Error
+			})
+		},
+		listAll: func(ctx context.Context, c *connections) ([]Sample, error) {
+			gormSamples := make([]SampleTable, 0)
+			err := c.gorm.WithContext(ctx).Find(&gormSamples).Error
+			return mapSamples(gormSamples, sampleFromGorm), err
+		},
+	},
+	{
+		library: "sqlc",
+		insert: func(ctx context.Context, c *connections, s Sample) error {
+			return c.sqlcQueries.CreateSampleNoReturn(ctx, sqlcdb.CreateSampleNoReturnParams{
+				Name:        s.Name,
+				Description: s.Description,
+				IntExample:  int32Ptr(s.IntExample),
+			})
+		},
+		insertReturning: func(ctx context.Context, c *connections, s Sample) (Sample, error) {
+			sc, err := c.sqlcQueries.CreateSampleWithReturn(ctx, sqlcdb.CreateSampleWithReturnParams{
+				Name:        s.Name,
+				Description: s.Description,
+				IntExample:  int32Ptr(s.IntExample),
+			})
+			return sampleFromSqlc(sc), err
+		},
+		getByID: func(ctx context.Context, c *connections, id int) (Sample, error) {
+			sc, err := c.sqlcQueries.GetSampleByID(ctx, int32(id))
+			return sampleFromSqlc(sc), err
+		},
+		updateName: func(ctx context.Context, c *connections, id int, name string) error {
+			return c.sqlcQueries.UpdateSampleName(ctx, sqlcdb.UpdateSampleNameParams{Name: name, ID: int32(id)})
+		},
+		sleep: func(ctx context.Context, c *connections, d time.Duration) error {
+			return c.sqlcQueries.Sleep(ctx, d.Seconds())
+		},
+		increment: func(ctx context.Context, c *connections, id int, p txretry.Policy) error {
+			return txretry.Pgx(ctx, c.sqlc, p, func(tx pgx.Tx) error {
+				q := c.sqlcQueries.WithTx(tx)
+				sc, err := q.GetSampleByID(ctx, int32(id))
+				if err != nil {
+					return err
+				}
+				value := int32(0)
+				if sc.IntExample != nil {
+					value = *sc.IntExample
+				}
+				return q.SetSampleIntExample(ctx, sqlcdb.SetSampleIntExampleParams{IntExample: ptr(value + 1), ID: int32(id)})
+			})
+		},
+		listAll: func(ctx context.Context, c *connections) ([]Sample, error) {
+			sqlcSamples, err := c.sqlcQueries.GetAllSamples(ctx)
+			return mapSamples(sqlcSamples, sampleFromSqlc), err
+		},
+	},
+	{
+		library: "sqlboiler",
+		insert: func(ctx context.Context, c *connections, s Sample) error {
+			sb := sqlboilerSample(s)
+			return sb.Insert(ctx, c.sqlboiler, boil.Infer())
+		},
+		insertReturning: func(ctx context.Context, c *connections, s Sample) (Sample, error) {
+			// sqlboiler handles returning automatically
+			sb := sqlboilerSample(s)
+			err := sb.Insert(ctx, c.sqlboiler, boil.Infer())
+			return sampleFromSqlboiler(sb), err
+		},
+		getByID: func(ctx context.Context, c *connections, id int) (Sample, error) {
+			sb, err := sqlbdb.FindSampleTable(ctx, c.sqlboiler, id)
+			if err != nil {
+				return Sample{}, err
+			}
+			return sampleFromSqlboiler(sb), nil
+		},
+		updateName: func(ctx context.Context, c *connections, id int, name string) error {
+			// sqlboiler sets updated_at itself, as long as it is one of the columns updated
+			sb := &sqlbdb.SampleTable{ID: id, Name: name}
+			return sb.Update(ctx, c.sqlboiler, boil.Whitelist(sqlbdb.SampleTableColumns.Name, sqlbdb.SampleTableColumns.UpdatedAt))
+		},
+		sleep: func(ctx context.Context, c *connections, d time.Duration) error {
+			_, err := queries.Raw("select pg_sleep($1)", d.Seconds()).ExecContext(ctx, c.sqlboiler)
+			return err
+		},
+		increment: func(ctx context.Context, c *connections, id int, p txretry.Policy) error {
+			return txretry.SQL(ctx, c.sqlboiler, p, func(tx *sql.Tx) error {
+				sb, err := sqlbdb.FindSampleTable(ctx, tx, id)
+				if err != nil {
+					return err
+				}
+				sb.IntExample = null.IntFrom(sb.IntExample.Int + 1)
+				return sb.Update(ctx, tx, boil.Whitelist(sqlbdb.SampleTableColumns.IntExample, sqlbdb.SampleTableColumns.UpdatedAt))
+			})
+		},
+		listAll: func(ctx context.Context, c *connections) ([]Sample, error) {
+			sqlbSamples, err := sqlbdb.SampleTables().All(ctx, c.sqlboiler)
+			return mapSamples(sqlbSamples, sampleFromSqlboiler), err
+		},
+	},
+}
+
+func libraryNames(libraries []libraryOps) []string {
+	names := make([]string, 0, len(libraries))
+	for _, l := range libraries {
+		names = append(names, l.library)
+	}
+	return names
+}
+
+func sqlboilerSample(s Sample) *sqlbdb.SampleTable {
+	return &sqlbdb.SampleTable{
+		Name:        s.Name,
+		Description: null.StringFromPtr(s.Description),
+		IntExample:  null.IntFromPtr(s.IntExample),
+	}
+}
+
+func int32Ptr(i *int) *int32 {
+	if i == nil {
+		return nil
+	}
+	return ptr(int32(*i))
+}
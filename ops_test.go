@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"go-orm-test/txretry"
+	"testing"
+)
+
+// TestLibrariesInsertAndRead runs every library in parallel, each in its own database, so the rows any one of them
+// counts are only the seeded ones and its own
+func TestLibrariesInsertAndRead(t *testing.T) {
+	for _, l := range libraries {
+		l := l
+		t.Run(l.library, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			c := newTestConnections(t)
+
+			before, err := l.listAll(ctx, c)
+			if err != nil {
+				t.Fatalf("list before insert: %v", err)
+			}
+			inserted, err := l.insertReturning(ctx, c, Sample{Name: l.library + " test", IntExample: ptr(7)})
+			if err != nil {
+				t.Fatalf("insert: %v", err)
+			}
+			read, err := l.getByID(ctx, c, inserted.ID)
+			if err != nil {
+				t.Fatalf("get: %v", err)
+			}
+			if read.Name != l.library+" test" || read.IntExample == nil || *read.IntExample != 7 {
+				t.Errorf("read back %+v, want the inserted row", read)
+			}
+			after, err := l.listAll(ctx, c)
+			if err != nil {
+				t.Fatalf("list after insert: %v", err)
+			}
+			if len(after) != len(before)+1 {
+				t.Errorf("listed %d rows after inserting one into %d, another test wrote to this database", len(after), len(before))
+			}
+		})
+	}
+}
+
+// TestLibrariesIncrementNull checks every library counts a null int_example as 0 when incrementing it
+func TestLibrariesIncrementNull(t *testing.T) {
+	for _, l := range libraries {
+		l := l
+		t.Run(l.library, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			c := newTestConnections(t)
+
+			inserted, err := l.insertReturning(ctx, c, Sample{Name: l.library + " null increment"})
+			if err != nil {
+				t.Fatalf("insert: %v", err)
+			}
+			if err := l.increment(ctx, c, inserted.ID, txretry.Default); err != nil {
+				t.Fatalf("increment: %v", err)
+			}
+			read, err := l.getByID(ctx, c, inserted.ID)
+			if err != nil {
+				t.Fatalf("get: %v", err)
+			}
+			if read.IntExample == nil || *read.IntExample != 1 {
+				t.Errorf("int_example is %v after incrementing null, want 1", read.IntExample)
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+)
+
+// poolResult is how one library did in the concurrent workload at one concurrency
+type poolResult struct {
+	Library     string        `json:"library"`
+	Pool        string        `json:"pool"`
+	Concurrency int           `json:"concurrency"`
+	Operations  int64         `json:"operations"`
+	Errors      int64         `json:"errors"`
+	Elapsed     time.Duration `json:"elapsed"`
+	PerSecond   float64       `json:"perSecond"`
+	Stats       poolStats     `json:"stats"`
+	FirstError  string        `json:"firstError,omitempty"`
+}
+
+// poolStats is what sql.DBStats and pgxpool.Stat have in common. The counters are for the run only, the connections
+// are as the run left them.
+type poolStats struct {
+	// MaxConns is 0 when the pool is unlimited
+	MaxConns int `json:"maxConns"`
+	Conns    int `json:"conns"`
+	InUse    int `json:"inUse"`
+	// Waits is how many times an operation had to wait for a connection, WaitTime how long they waited in total. For
+	// pgxpool it is the time spent acquiring any connection, which is close to nothing unless it had to wait.
+	Waits    int64         `json:"waits"`
+	WaitTime time.Duration `json:"waitTime"`
+	// Timed is how many acquires WaitTime adds up, the waits for database/sql and every acquire for pgxpool
+	Timed int64 `json:"timed"`
+	// Closed is how many connections were closed for being idle or too old
+	Closed int64 `json:"closed"`
+}
+
+// runPool runs the same concurrent workload with every library at every concurrency, sqlc on its pgxpool and the
+// others on their database/sql pools, and reports throughput, errors and how long operations waited for a connection.
+// The pools are sized by the pool config, which the flags override. It returns 1 if any operation failed.
+func runPool(ctx context.Context, args []string) int {
+	flags := flag.NewFlagSet("pool", flag.ExitOnError)
+	dsn := flags.String("dsn", connectionString, "connection string")
+	concurrency := flags.String("concurrency", "1,4,16,64", "comma separated goroutines per library, the workload runs once with each")
+	ops := flags.Int("ops", 200, "operations per goroutine, every fifth an insert and the others a get by id")
+	format := flags.String("format", "table", "output format: table or json")
+	cfg := pools
+	flags.IntVar(&cfg.SQL.MaxOpenConns, "max-open-conns", cfg.SQL.MaxOpenConns, "most connections in each database/sql pool, 0 for unlimited")
+	flags.IntVar(&cfg.SQL.MaxIdleConns, "max-idle-conns", cfg.SQL.MaxIdleConns, "idle connections each database/sql pool keeps, 0 for the default of 2, -1 for none")
+	flags.DurationVar((*time.Duration)(&cfg.SQL.ConnMaxLifetime), "conn-max-lifetime", time.Duration(cfg.SQL.ConnMaxLifetime), "how long a database/sql connection is used before being replaced, 0 for forever")
+	flags.DurationVar((*time.Duration)(&cfg.SQL.ConnMaxIdleTime), "conn-max-idle-time", time.Duration(cfg.SQL.ConnMaxIdleTime), "how long a database/sql connection may stay idle before being closed, 0 for forever")
+	maxConns := flags.Int("sqlc-max-conns", int(cfg.Pgxpool.MaxConns), "most connections in the sqlc pool, 0 for the pgxpool default")
+	minConns := flags.Int("sqlc-min-conns", int(cfg.Pgxpool.MinConns), "connections the sqlc pool keeps open even when idle")
+	flags.DurationVar((*time.Duration)(&cfg.Pgxpool.HealthCheckPeriod), "sqlc-health-check", time.Duration(cfg.Pgxpool.HealthCheckPeriod), "how often the sqlc pool checks idle connections, 0 for the pgxpool default")
+	flags.DurationVar((*time.Duration)(&cfg.Pgxpool.MaxConnLifetime), "sqlc-max-lifetime", time.Duration(cfg.Pgxpool.MaxConnLifetime), "how long a sqlc pool connection is used before being replaced, 0 for the pgxpool default")
+	flags.DurationVar((*time.Duration)(&cfg.Pgxpool.MaxConnIdleTime), "sqlc-max-idle-time", time.Duration(cfg.Pgxpool.MaxConnIdleTime), "how long a sqlc pool connection may stay idle before being closed, 0 for the pgxpool default")
+	_ = flags.Parse(args)
+	cfg.Pgxpool.MaxConns, cfg.Pgxpool.MinConns = int32(*maxConns), int32(*minConns)
+
+	levels, err := parseConcurrency(*concurrency)
+	if err != nil {
+		log.Fatalf("invalid -concurrency: %v", err)
+	}
+
+	conns, err := openConnections(ctx, *dsn, withPool(cfg))
+	if err != nil {
+		log.Fatalf("unable to connect: %v", err)
+	}
+	defer conns.Close()
+	// migrated on a pool of its own, as the go migrations need two connections and -max-open-conns may be 1
+	migrationDB, err := sql.Open(driverName, *dsn)
+	if err != nil {
+		log.Fatalf("unable to connect: %v", err)
+	}
+	migrateWithGoose(migrationDB)
+	safeClose(migrationDB)
+
+	results := make([]poolResult, 0, len(levels)*len(libraries))
+	for _, workers := range levels {
+		for _, l := range libraries {
+			results = append(results, runConcurrentWorkload(ctx, conns, l, workers, *ops))
+		}
+	}
+
+	switch *format {
+	case "json":
+		b, _ := json.MarshalIndent(results, "", "  ")
+		fmt.Println(string(b))
+	case "table":
+		printPoolResults(results)
+	default:
+		log.Fatalf("unknown format %q", *format)
+	}
+
+	for _, r := range results {
+		if r.Errors > 0 {
+			return 1
+		}
+	}
+	return 0
+}
+
+// parseConcurrency parses a comma separated list of positive numbers
+func parseConcurrency(s string) ([]int, error) {
+	var levels []int
+	for _, field := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, err
+		}
+		if n < 1 {
+			return nil, fmt.Errorf("%d is not a positive number", n)
+		}
+		levels = append(levels, n)
+	}
+	return levels, nil
+}
+
+// runConcurrentWorkload runs ops operations on each of workers goroutines with library l, all reading the same row
+// inserted beforehand
+func runConcurrentWorkload(ctx context.Context, c *connections, l libraryOps, workers, ops int) poolResult {
+	r := poolResult{Library: l.library, Pool: poolKind(l.library), Concurrency: workers}
+	seed, err := l.insertReturning(ctx, c, Sample{Name: l.library + " pool seed"})
+	if err != nil {
+		r.Errors, r.FirstError = 1, err.Error()
+		return r
+	}
+
+	var operations, errs atomic.Int64
+	var firstErr sync.Once
+	var wg sync.WaitGroup
+	before := libraryPoolStats(c, l.library)
+	start := time.Now()
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < ops; i++ {
+				var err error
+				if i%5 == 4 {
+					err = l.insert(ctx, c, Sample{Name: fmt.Sprintf("%s pool %d-%d", l.library, w, i)})
+				} else {
+					_, err = l.getByID(ctx, c, seed.ID)
+				}
+				operations.Add(1)
+				if err != nil {
+					errs.Add(1)
+					firstErr.Do(func() { r.FirstError = err.Error() })
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	r.Elapsed = time.Since(start)
+	r.Operations, r.Errors = operations.Load(), errs.Load()
+	r.PerSecond = float64(r.Operations) / r.Elapsed.Seconds()
+	r.Stats = libraryPoolStats(c, l.library).since(before)
+	return r
+}
+
+// poolKind is the pool a library's operations run on
+func poolKind(library string) string {
+	if library == "sqlc" {
+		return "pgxpool"
+	}
+	return "database/sql"
+}
+
+// libraryDB is the database/sql pool of library, nil for sqlc which runs on a pgxpool
+func libraryDB(c *connections, library string) *sql.DB {
+	switch library {
+	case "custom":
+		return c.custom
+	case "sqlx":
+		return c.sqlx.DB
+	case "gorm":
+		db, _ := c.gorm.DB()
+		return db
+	case "sqlboiler":
+		return c.sqlboiler
+	default:
+		return nil
+	}
+}
+
+// libraryPoolStats reads the stats of library's pool
+func libraryPoolStats(c *connections, library string) poolStats {
+	if library == "sqlc" {
+		s := c.sqlc.Stat()
+		return poolStats{
+			MaxConns: int(s.MaxConns()),
+			Conns:    int(s.TotalConns()),
+			InUse:    int(s.AcquiredConns()),
+			Waits:    s.EmptyAcquireCount(),
+			WaitTime: s.AcquireDuration(),
+			Timed:    s.AcquireCount(),
+			Closed:   s.MaxIdleDestroyCount() + s.MaxLifetimeDestroyCount(),
+		}
+	}
+	db := libraryDB(c, library)
+	if db == nil {
+		return poolStats{}
+	}
+	s := db.Stats()
+	return poolStats{
+		MaxConns: s.MaxOpenConnections,
+		Conns:    s.OpenConnections,
+		InUse:    s.InUse,
+		Waits:    s.WaitCount,
+		WaitTime: s.WaitDuration,
+		Timed:    s.WaitCount,
+		Closed:   s.MaxIdleClosed + s.MaxIdleTimeClosed + s.MaxLifetimeClosed,
+	}
+}
+
+// since turns the counters into how much they grew after before was read
+func (s poolStats) since(before poolStats) poolStats {
+	s.Waits -= before.Waits
+	s.WaitTime -= before.WaitTime
+	s.Timed -= before.Timed
+	s.Closed -= before.Closed
+	return s
+}
+
+func printPoolResults(results []poolResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "CONCURRENCY\tLIBRARY\tPOOL\tOPS\tERRORS\tELAPSED\tOPS/S\tCONNS\tMAX\tWAITS\tWAIT TIME\tAVG WAIT\tCLOSED")
+	for _, r := range results {
+		maxConns, avgWait := "unlimited", time.Duration(0)
+		if r.Stats.MaxConns > 0 {
+			maxConns = strconv.Itoa(r.Stats.MaxConns)
+		}
+		if r.Stats.Timed > 0 {
+			avgWait = r.Stats.WaitTime / time.Duration(r.Stats.Timed)
+		}
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\t%s\t%.0f\t%d\t%s\t%d\t%s\t%s\t%d\n",
+			r.Concurrency, r.Library, r.Pool, r.Operations, r.Errors, r.Elapsed.Round(time.Millisecond), r.PerSecond,
+			r.Stats.Conns, maxConns, r.Stats.Waits, r.Stats.WaitTime.Round(time.Microsecond), avgWait.Round(time.Microsecond),
+			r.Stats.Closed)
+	}
+	_ = w.Flush()
+
+	for _, r := range results {
+		if r.FirstError != "" {
+			fmt.Printf("%s at %d: %s\n", r.Library, r.Concurrency, r.FirstError)
+		}
+	}
+}
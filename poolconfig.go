@@ -0,0 +1,121 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// poolConfigFile is the pool config file, with a pool section per environment like the migrations config
+type poolConfigFile struct {
+	Environments map[string]poolConfig `json:"environments"`
+}
+
+// poolConfig sizes the database/sql pools custom, sqlx, gorm and sqlboiler run on, and the pgxpool sqlc runs on
+type poolConfig struct {
+	SQL     sqlPoolConfig `json:"sql"`
+	Pgxpool pgxPoolConfig `json:"pgxpool"`
+}
+
+// sqlPoolConfig are the database/sql pool settings, zero values keep the database/sql defaults. As 0 idle connections
+// is the default of 2, no idle connections is -1.
+type sqlPoolConfig struct {
+	MaxOpenConns    int          `json:"maxOpenConns"`
+	MaxIdleConns    int          `json:"maxIdleConns"`
+	ConnMaxLifetime jsonDuration `json:"connMaxLifetime"`
+	ConnMaxIdleTime jsonDuration `json:"connMaxIdleTime"`
+}
+
+// apply sets the non zero values on db
+func (p sqlPoolConfig) apply(db *sql.DB) {
+	if p.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(p.MaxOpenConns)
+	}
+	// database/sql keeps none for anything below 1
+	if p.MaxIdleConns != 0 {
+		db.SetMaxIdleConns(p.MaxIdleConns)
+	}
+	if p.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(time.Duration(p.ConnMaxLifetime))
+	}
+	if p.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(time.Duration(p.ConnMaxIdleTime))
+	}
+}
+
+// pgxPoolConfig are the pgxpool settings, zero values keep the pgxpool defaults
+type pgxPoolConfig struct {
+	MaxConns          int32        `json:"maxConns"`
+	MinConns          int32        `json:"minConns"`
+	HealthCheckPeriod jsonDuration `json:"healthCheckPeriod"`
+	MaxConnLifetime   jsonDuration `json:"maxConnLifetime"`
+	MaxConnIdleTime   jsonDuration `json:"maxConnIdleTime"`
+}
+
+// apply sets the non zero values on config
+func (p pgxPoolConfig) apply(config *pgxpool.Config) {
+	if p.MaxConns > 0 {
+		config.MaxConns = p.MaxConns
+	}
+	if p.MinConns > 0 {
+		config.MinConns = p.MinConns
+	}
+	if p.HealthCheckPeriod > 0 {
+		config.HealthCheckPeriod = time.Duration(p.HealthCheckPeriod)
+	}
+	if p.MaxConnLifetime > 0 {
+		config.MaxConnLifetime = time.Duration(p.MaxConnLifetime)
+	}
+	if p.MaxConnIdleTime > 0 {
+		config.MaxConnIdleTime = time.Duration(p.MaxConnIdleTime)
+	}
+}
+
+// jsonDuration is a time.Duration written as a string like "30s" in json
+type jsonDuration time.Duration
+
+func (d jsonDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *jsonDuration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = jsonDuration(parsed)
+	return nil
+}
+
+// pools is the pool config of the environment, loaded in main and used by openConnections unless replaced with
+// withPool
+var pools poolConfig
+
+// loadPoolConfig reads the pool section of env from the pool config file, the library defaults are kept when the file
+// does not exist
+func loadPoolConfig(configPath, env string) (poolConfig, error) {
+	b, err := os.ReadFile(configPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return poolConfig{}, nil
+	} else if err != nil {
+		return poolConfig{}, err
+	}
+	var config poolConfigFile
+	if err := json.Unmarshal(b, &config); err != nil {
+		return poolConfig{}, fmt.Errorf("invalid pool config %s: %w", configPath, err)
+	}
+	pool, ok := config.Environments[env]
+	if !ok {
+		return poolConfig{}, fmt.Errorf("environment %q is not in the pool config", env)
+	}
+	return pool, nil
+}
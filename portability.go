@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// portabilityOptions control the report of runPortability, they are set from the flags added by portabilityFlags
+type portabilityOptions struct {
+	format    *string
+	precision *time.Duration
+	color     *bool
+}
+
+func portabilityFlags(flags *flag.FlagSet) portabilityOptions {
+	return portabilityOptions{
+		format:    flags.String("format", "table", "output format: table or json"),
+		precision: flags.Duration("precision", time.Microsecond, "timestamps are truncated to this precision before comparing"),
+		color:     flags.Bool("color", isTerminal(os.Stdout), "color the table output"),
+	}
+}
+
+// scenarioResult is the outcome of one scenario run by one library
+type scenarioResult struct {
+	Library   string `json:"library"`
+	Operation string `json:"operation"`
+	Error     string `json:"error,omitempty"`
+}
+
+// runPortability runs every scenario with each library against a database other than postgres, then reports which
+// operations failed and where the libraries' results differ. It returns 1 if any failed or differ.
+func runPortability(ctx context.Context, c *connections, libraries []libraryOps, opts portabilityOptions) int {
+	results := make([]scenarioResult, 0, len(libraries)*len(captureScenarios))
+	for _, l := range libraries {
+		state := &captureState{}
+		for _, s := range captureScenarios {
+			r := scenarioResult{Library: l.library, Operation: s.name}
+			if err := s.run(ctx, c, l, state); err != nil {
+				r.Error = err.Error()
+			}
+			results = append(results, r)
+		}
+	}
+
+	var mismatches []sampleMismatch
+	samples, err := listAllSamples(ctx, c, libraries)
+	if err == nil {
+		mismatches = diffSamples(samples, libraryNames(libraries), *opts.precision)
+	}
+
+	switch *opts.format {
+	case "json":
+		b, _ := json.MarshalIndent(struct {
+			Results    []scenarioResult `json:"results"`
+			Mismatches []sampleMismatch `json:"mismatches"`
+		}{results, mismatches}, "", "  ")
+		fmt.Println(string(b))
+	case "table":
+		printScenarioResults(results, libraries)
+		if err != nil {
+			fmt.Printf("\nunable to compare results: %v\n", err)
+		} else if len(mismatches) == 0 {
+			fmt.Println("\nevery library returned the same rows")
+		} else {
+			fmt.Println()
+			printMismatchTable(os.Stdout, mismatches, libraryNames(libraries), *opts.color)
+		}
+	default:
+		log.Fatalf("unknown format %q", *opts.format)
+	}
+
+	for _, r := range results {
+		if r.Error != "" {
+			return 1
+		}
+	}
+	if err != nil || len(mismatches) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// printScenarioResults prints a row per library with the outcome of every scenario and how its code differs from the
+// postgres one, followed by the errors
+func printScenarioResults(results []scenarioResult, libraries []libraryOps) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprint(w, "LIBRARY")
+	for _, s := range captureScenarios {
+		_, _ = fmt.Fprintf(w, "\t%s", s.name)
+	}
+	_, _ = fmt.Fprintln(w, "\tCODE")
+
+	var failures []string
+	for _, l := range libraries {
+		_, _ = fmt.Fprint(w, l.library)
+		for _, r := range results {
+			if r.Library != l.library {
+				continue
+			}
+			outcome := "ok"
+			if r.Error != "" {
+				outcome = "error"
+				failures = append(failures, fmt.Sprintf("%s %s: %s", r.Library, r.Operation, r.Error))
+			}
+			_, _ = fmt.Fprintf(w, "\t%s", outcome)
+		}
+		_, _ = fmt.Fprintf(w, "\t%s\n", l.note)
+	}
+	_ = w.Flush()
+
+	if len(failures) > 0 {
+		fmt.Println()
+	}
+	for _, f := range failures {
+		fmt.Println(f)
+	}
+}
@@ -17,4 +17,13 @@ select description from test.sample_table;
 select id, description from test.sample_table;
 
 -- name: GetSampleByID :one
-select * from test.sample_table where id = $1;
\ No newline at end of file
+select * from test.sample_table where id = $1;
+
+-- name: UpdateSampleName :exec
+update test.sample_table set name = $1, updated_at = now() where id = $2;
+
+-- name: Sleep :exec
+select pg_sleep($1);
+
+-- name: SetSampleIntExample :exec
+update test.sample_table set int_example = $1, updated_at = now() where id = $2;
\ No newline at end of file
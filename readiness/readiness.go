@@ -0,0 +1,98 @@
+// Package readiness waits for a database to accept connections, retrying with exponential backoff while it is
+// starting up and giving up straight away on errors retrying won't fix, like a wrong password.
+package readiness
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Config controls how Wait retries
+type Config struct {
+	// Initial is the wait after the first failure, it doubles after every following one up to Max. Waits shorter
+	// than MinWait, including a zero Initial or Max, are raised to it so a server that is down isn't probed in a loop
+	Initial time.Duration
+	Max     time.Duration
+	// Jitter randomizes every wait by up to this fraction of it, so clients started together don't retry together
+	Jitter float64
+	// Timeout is how long to keep trying in total, zero only stops when ctx is done
+	Timeout time.Duration
+	// OnRetry is called before every wait, when set
+	OnRetry func(attempt int, wait time.Duration, err error)
+}
+
+// MinWait is the shortest wait between two probes
+const MinWait = 10 * time.Millisecond
+
+// Default suits a database started next to the program, e.g. with docker compose
+var Default = Config{
+	Initial: 100 * time.Millisecond,
+	Max:     2 * time.Second,
+	Jitter:  0.2,
+	Timeout: 30 * time.Second,
+}
+
+// Wait calls probe until it succeeds, it returns an error retrying won't fix, the timeout is reached, or ctx is done
+func Wait(ctx context.Context, cfg Config, probe func(ctx context.Context) error) error {
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Timeout)
+		defer cancel()
+	}
+
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+	backoff := cfg.Initial
+	if backoff < MinWait {
+		backoff = MinWait
+	}
+	for attempt := 1; ; attempt++ {
+		err := probe(ctx)
+		if err == nil {
+			return nil
+		}
+		if Permanent(err) {
+			return fmt.Errorf("not retrying: %w", err)
+		}
+
+		wait := backoff + time.Duration((random.Float64()*2-1)*cfg.Jitter*float64(backoff))
+		if wait < MinWait {
+			wait = MinWait
+		}
+		if cfg.OnRetry != nil {
+			cfg.OnRetry(attempt, wait, err)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			if cfg.Timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("not ready after %s: %w", cfg.Timeout, err)
+			}
+			return fmt.Errorf("%v: %w", ctx.Err(), err)
+		case <-timer.C:
+		}
+
+		if backoff *= 2; backoff > cfg.Max {
+			backoff = cfg.Max
+		}
+		if backoff < MinWait {
+			backoff = MinWait
+		}
+	}
+}
+
+// Permanent reports whether err comes from the server rejecting the connection in a way that won't change by
+// retrying: failed authentication (sqlstate class 28) or a database that doesn't exist (3D000). Anything else, like
+// connection refused or "the database system is starting up", is worth retrying.
+func Permanent(err error) bool {
+	// the pgconn errors of pgx v4 and v5 both have SQLState
+	var pgErr interface{ SQLState() string }
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	code := pgErr.SQLState()
+	return len(code) == 5 && (code[:2] == "28" || code == "3D000")
+}
@@ -0,0 +1,145 @@
+package readiness
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// sqlStateError is an error from the server with a sqlstate, like the pgconn errors
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "sqlstate " + string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+// failing returns a probe failing with err the first failures times it is called, and the number of calls so far
+func failing(failures int, err error) (func(context.Context) error, *int) {
+	calls := 0
+	return func(context.Context) error {
+		calls++
+		if calls <= failures {
+			return err
+		}
+		return nil
+	}, &calls
+}
+
+// waitsOf runs Wait and returns the waits it made
+func waitsOf(t *testing.T, cfg Config, failures int) []time.Duration {
+	t.Helper()
+	waits := make([]time.Duration, 0)
+	cfg.OnRetry = func(_ int, wait time.Duration, _ error) {
+		waits = append(waits, wait)
+	}
+	probe, _ := failing(failures, errors.New("connection refused"))
+	if err := Wait(context.Background(), cfg, probe); err != nil {
+		t.Fatal(err)
+	}
+	return waits
+}
+
+func TestWaitBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      Config
+		failures int
+		want     []time.Duration
+	}{
+		{
+			name:     "doubles up to max",
+			cfg:      Config{Initial: 10 * time.Millisecond, Max: 40 * time.Millisecond},
+			failures: 5,
+			want:     []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 40 * time.Millisecond, 40 * time.Millisecond},
+		},
+		{
+			name:     "zero initial and max wait the minimum",
+			cfg:      Config{},
+			failures: 3,
+			want:     []time.Duration{MinWait, MinWait, MinWait},
+		},
+		{
+			name:     "negative initial grows from the minimum",
+			cfg:      Config{Initial: -time.Second, Max: time.Second},
+			failures: 2,
+			want:     []time.Duration{MinWait, 2 * MinWait},
+		},
+		{
+			name:     "no failures",
+			cfg:      Default,
+			failures: 0,
+			want:     []time.Duration{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := waitsOf(t, tt.cfg, tt.failures); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("waited %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWaitJitter(t *testing.T) {
+	backoff := 20 * time.Millisecond
+	cfg := Config{Initial: backoff, Max: backoff, Jitter: 0.5}
+	waits := waitsOf(t, cfg, 10)
+	distinct := map[time.Duration]bool{}
+	for _, wait := range waits {
+		if wait < backoff/2 || wait > backoff*3/2 {
+			t.Errorf("waited %s, want between %s and %s", wait, backoff/2, backoff*3/2)
+		}
+		distinct[wait] = true
+	}
+	if len(distinct) < 2 {
+		t.Errorf("waited %v, want the waits to differ", waits)
+	}
+}
+
+func TestWaitPermanent(t *testing.T) {
+	cfg := Config{Initial: MinWait, Max: MinWait, OnRetry: func(attempt int, _ time.Duration, _ error) {
+		t.Errorf("retried attempt %d", attempt)
+	}}
+	probe, calls := failing(10, sqlStateError("28P01"))
+	err := Wait(context.Background(), cfg, probe)
+	if !errors.Is(err, sqlStateError("28P01")) {
+		t.Errorf("got %v, want the authentication error", err)
+	}
+	if *calls != 1 {
+		t.Errorf("probed %d times, want 1", *calls)
+	}
+}
+
+func TestWaitTimeout(t *testing.T) {
+	refused := errors.New("connection refused")
+	probe, _ := failing(1000, refused)
+	err := Wait(context.Background(), Config{Initial: MinWait, Max: MinWait, Timeout: 50 * time.Millisecond}, probe)
+	if !errors.Is(err, refused) {
+		t.Errorf("got %v, want the last probe error", err)
+	}
+}
+
+func TestPermanent(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{sqlStateError("28P01"), true}, // invalid password
+		{sqlStateError("28000"), true}, // invalid authorization
+		{sqlStateError("3D000"), true}, // database does not exist
+		{fmt.Errorf("connecting: %w", sqlStateError("3D000")), true},
+		{sqlStateError("57P03"), false}, // the database system is starting up
+		{sqlStateError("08006"), false}, // connection failure
+		{sqlStateError("3D001"), false},
+		{sqlStateError("28"), false},
+		{errors.New("dial tcp 127.0.0.1:5432: connect: connection refused"), false},
+		{context.DeadlineExceeded, false},
+	}
+	for _, tt := range tests {
+		if got := Permanent(tt.err); got != tt.want {
+			t.Errorf("Permanent(%v) = %t, want %t", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"github.com/pressly/goose/v3"
+	"log"
+	"math"
+	"path"
+	"strings"
+)
+
+// runMigrateRoundTrip checks every migration's down section in a scratch database. For each migration in order it is
+// applied, rolled back and applied again, failing if the schema after the rollback is not what it was before the
+// migration, or if applying it again does not give the same schema as the first time.
+func runMigrateRoundTrip(ctx context.Context, args []string) int {
+	flags := flag.NewFlagSet("migrate roundtrip", flag.ExitOnError)
+	dsn := flags.String("dsn", connectionString, "connection string, the scratch database is created next to it")
+	_ = flags.Parse(args)
+
+	if err := roundTripMigrations(ctx, *dsn); err != nil {
+		log.Printf("migrate roundtrip: %v", err)
+		return 1
+	}
+	return 0
+}
+
+// roundTripMigrations round trips every migration in order in a scratch database, which is dropped before returning
+func roundTripMigrations(ctx context.Context, dsn string) error {
+	db, drop, err := openScratchDatabase(ctx, dsn, "roundtrip")
+	if err != nil {
+		return fmt.Errorf("unable to create scratch database: %w", err)
+	}
+	defer drop()
+	if err := setMigrationSource(db); err != nil {
+		return err
+	}
+
+	found, err := goose.CollectMigrations(migrationsDir, 0, math.MaxInt64)
+	if err != nil {
+		return err
+	}
+	for _, m := range found {
+		name := path.Base(m.Source)
+		if err := roundTripMigration(ctx, db, m.Version); err != nil {
+			fmt.Printf("FAIL %s: %v\n", name, err)
+			// the schema is in an unknown state, so later migrations can't be checked
+			return fmt.Errorf("%s does not round trip", name)
+		}
+		fmt.Printf("ok   %s\n", name)
+	}
+	return nil
+}
+
+// roundTripMigration applies, rolls back and applies again the migration with the given version, which must be the
+// next one to apply
+func roundTripMigration(ctx context.Context, db *sql.DB, version int64) error {
+	before, err := snapshotSchema(ctx, db)
+	if err != nil {
+		return err
+	}
+	if err := goose.UpTo(db, migrationsDir, version); err != nil {
+		return fmt.Errorf("up: %w", err)
+	}
+	applied, err := snapshotSchema(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	if err := goose.Down(db, migrationsDir); err != nil {
+		return fmt.Errorf("down: %w", err)
+	}
+	rolledBack, err := snapshotSchema(ctx, db)
+	if err != nil {
+		return err
+	}
+	if diff := diffLines(before, rolledBack); len(diff) > 0 {
+		return fmt.Errorf("down does not reverse up, schema differs from before the migration:\n%s", joinLines(diff))
+	}
+
+	if err := goose.UpTo(db, migrationsDir, version); err != nil {
+		return fmt.Errorf("up again: %w", err)
+	}
+	reapplied, err := snapshotSchema(ctx, db)
+	if err != nil {
+		return err
+	}
+	if diff := diffLines(applied, reapplied); len(diff) > 0 {
+		return fmt.Errorf("applying again gives a different schema:\n%s", joinLines(diff))
+	}
+	return nil
+}
+
+func joinLines(lines []string) string {
+	return "    " + strings.Join(lines, "\n    ")
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"github.com/pressly/goose/v3"
+	"log"
+	"os"
+	"strings"
+)
+
+const schemaFile = "schema.sql"
+
+const schemaFileHeader = `-- Code generated by "go run . schema -write" from the goose migrations. DO NOT EDIT.
+-- This is the schema sqlc generates from, "go run . schema" checks it matches the migrations.
+`
+
+// runSchemaDrift applies the migrations to a scratch database and either rewrites schema.sql from it, or applies
+// schema.sql to a second scratch database and fails if the two schemas differ
+func runSchemaDrift(ctx context.Context, args []string) int {
+	flags := flag.NewFlagSet("schema", flag.ExitOnError)
+	dsn := flags.String("dsn", connectionString, "connection string, the scratch databases are created next to it")
+	write := flags.Bool("write", false, "regenerate schema.sql instead of checking it")
+	_ = flags.Parse(args)
+
+	if *write {
+		if err := writeSchemaFile(ctx, *dsn); err != nil {
+			log.Printf("schema: %v", err)
+			return 1
+		}
+		fmt.Printf("wrote %s, regenerate the models with `go run . generate`\n", schemaFile)
+		return 0
+	}
+
+	diff, err := checkSchemaFile(ctx, *dsn)
+	if err != nil {
+		log.Printf("schema: %v", err)
+		return 1
+	}
+	if len(diff) > 0 {
+		fmt.Printf("%s differs from the migrations (- migrations, + %s):\n%s\n", schemaFile, schemaFile, strings.Join(diff, "\n"))
+		fmt.Println("run `go run . schema -write` to regenerate it")
+		return 1
+	}
+	fmt.Printf("%s matches the migrations\n", schemaFile)
+	return 0
+}
+
+// writeSchemaFile rewrites schema.sql from a scratch database the migrations were applied to
+func writeSchemaFile(ctx context.Context, dsn string) error {
+	migrated, drop, err := openMigratedScratchDatabase(ctx, dsn, "schema_migrated")
+	if err != nil {
+		return err
+	}
+	defer drop()
+	ddl, err := dumpSchemaDDL(ctx, migrated)
+	if err != nil {
+		return fmt.Errorf("unable to dump schema: %w", err)
+	}
+	return os.WriteFile(schemaFile, []byte(schemaFileHeader+"\n"+ddl), 0o644)
+}
+
+// checkSchemaFile applies schema.sql and the migrations to a scratch database each and returns how their schemas
+// differ
+func checkSchemaFile(ctx context.Context, dsn string) ([]string, error) {
+	migrated, dropMigrated, err := openMigratedScratchDatabase(ctx, dsn, "schema_migrated")
+	if err != nil {
+		return nil, err
+	}
+	defer dropMigrated()
+
+	fromFile, dropFromFile, err := openScratchDatabase(ctx, dsn, "schema_file")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create scratch database: %w", err)
+	}
+	defer dropFromFile()
+	contents, err := os.ReadFile(schemaFile)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fromFile.ExecContext(ctx, string(contents)); err != nil {
+		return nil, fmt.Errorf("unable to apply %s: %w", schemaFile, err)
+	}
+
+	expected, err := snapshotSchema(ctx, migrated)
+	if err != nil {
+		return nil, err
+	}
+	actual, err := snapshotSchema(ctx, fromFile)
+	if err != nil {
+		return nil, err
+	}
+	return diffLines(expected, actual), nil
+}
+
+// openMigratedScratchDatabase creates a scratch database with the migrations applied, the returned function closes and
+// drops it
+func openMigratedScratchDatabase(ctx context.Context, dsn, prefix string) (*sql.DB, func(), error) {
+	db, drop, err := openScratchDatabase(ctx, dsn, prefix)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to create scratch database: %w", err)
+	}
+	if err := setMigrationSource(db); err != nil {
+		drop()
+		return nil, nil, err
+	}
+	if err := goose.Up(db, migrationsDir); err != nil {
+		drop()
+		return nil, nil, fmt.Errorf("unable to migrate scratch database: %w", err)
+	}
+	return db, drop, nil
+}
+
+// openScratchDatabase creates a scratch database and connects to it, the returned function closes and drops it
+func openScratchDatabase(ctx context.Context, connectionString, prefix string) (*sql.DB, func(), error) {
+	scratchDSN, drop, err := createScratchDatabase(ctx, connectionString, prefix)
+	if err != nil {
+		return nil, nil, err
+	}
+	db, err := sql.Open(driverName, scratchDSN)
+	if err != nil {
+		drop()
+		return nil, nil, err
+	}
+	return db, func() {
+		safeClose(db)
+		drop()
+	}, nil
+}
+
+// schemaColumn is a column as rendered in the dumped ddl
+type schemaColumn struct {
+	name     string
+	dataType string
+	notNull  bool
+	def      sql.NullString
+}
+
+// serialTypes are rendered in place of integer columns defaulting to their own sequence
+var serialTypes = map[string]string{
+	"smallint": "smallserial",
+	"integer":  "serial",
+	"bigint":   "bigserial",
+}
+
+// dumpSchemaDDL renders the non system schemas, tables, constraints and indexes of the database as create statements,
+// in a stable order and format
+func dumpSchemaDDL(ctx context.Context, db *sql.DB) (string, error) {
+	var ddl strings.Builder
+
+	schemas, err := queryStrings(ctx, db, `select nspname
+from pg_namespace
+where nspname not in ('pg_catalog', 'information_schema', 'pg_toast', 'public')
+  and nspname not like 'pg_temp%' and nspname not like 'pg_toast_temp%'
+order by nspname`)
+	if err != nil {
+		return "", err
+	}
+	for _, schema := range schemas {
+		_, _ = fmt.Fprintf(&ddl, "create schema %s;\n\n", schema)
+	}
+
+	tables, err := queryStrings(ctx, db, `select n.nspname || '.' || c.relname
+from pg_class c
+         join pg_namespace n on n.oid = c.relnamespace
+where c.relkind = 'r'
+  and n.nspname not in ('pg_catalog', 'information_schema')
+  and c.relname <> 'goose_db_version'
+order by 1`)
+	if err != nil {
+		return "", err
+	}
+	for _, table := range tables {
+		if err := dumpTableDDL(ctx, db, table, &ddl); err != nil {
+			return "", err
+		}
+	}
+	return ddl.String(), nil
+}
+
+func dumpTableDDL(ctx context.Context, db *sql.DB, table string, ddl *strings.Builder) error {
+	rows, err := db.QueryContext(ctx, `select a.attname, format_type(a.atttypid, a.atttypmod), a.attnotnull, pg_get_expr(d.adbin, d.adrelid)
+from pg_attribute a
+         left join pg_attrdef d on d.adrelid = a.attrelid and d.adnum = a.attnum
+where a.attrelid = $1::regclass
+  and a.attnum > 0
+  and not a.attisdropped
+order by a.attnum`, table)
+	if err != nil {
+		return err
+	}
+	columns := make([]schemaColumn, 0)
+	nameWidth, typeWidth := 0, 0
+	for rows.Next() {
+		var c schemaColumn
+		if err := rows.Scan(&c.name, &c.dataType, &c.notNull, &c.def); err != nil {
+			safeClose(rows)
+			return err
+		}
+		if serial, ok := serialTypes[c.dataType]; ok && strings.HasPrefix(c.def.String, "nextval(") {
+			c.dataType, c.def = serial, sql.NullString{}
+		}
+		if len(c.name) > nameWidth {
+			nameWidth = len(c.name)
+		}
+		if len(c.dataType) > typeWidth {
+			typeWidth = len(c.dataType)
+		}
+		columns = append(columns, c)
+	}
+	safeClose(rows)
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	constraints, err := queryStrings(ctx, db, `select 'constraint ' || conname || ' ' || pg_get_constraintdef(oid)
+from pg_constraint
+where conrelid = $1::regclass
+order by case contype when 'p' then 0 when 'u' then 1 when 'f' then 2 else 3 end, conname`, table)
+	if err != nil {
+		return err
+	}
+
+	lines := make([]string, 0, len(columns)+len(constraints))
+	for _, c := range columns {
+		line := fmt.Sprintf("%-*s %-*s", nameWidth, c.name, typeWidth, c.dataType)
+		if c.notNull {
+			line += " not null"
+		}
+		if c.def.Valid {
+			line += " default " + c.def.String
+		}
+		lines = append(lines, strings.TrimRight(line, " "))
+	}
+	lines = append(lines, constraints...)
+	_, _ = fmt.Fprintf(ddl, "create table %s\n(\n    %s\n);\n", table, strings.Join(lines, ",\n    "))
+
+	// indexes backing constraints are created by the constraints
+	indexes, err := queryStrings(ctx, db, `select indexdef
+from pg_indexes i
+where i.schemaname || '.' || i.tablename = $1
+  and not exists (select 1 from pg_constraint c where c.conindid = (quote_ident(i.schemaname) || '.' || quote_ident(i.indexname))::regclass)
+order by indexname`, table)
+	if err != nil {
+		return err
+	}
+	for _, index := range indexes {
+		_, _ = fmt.Fprintf(ddl, "\n%s;\n", index)
+	}
+	ddl.WriteString("\n")
+	return nil
+}
+
+// queryStrings returns the single text column of every row of the query
+func queryStrings(ctx context.Context, db *sql.DB, query string, args ...any) ([]string, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer safeClose(rows)
+	values := make([]string, 0)
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+)
+
+// schemaSnapshotQueries describe the user schemas of a database, one line per object, skipping the table goose uses for
+// bookkeeping
+var schemaSnapshotQueries = []string{
+	// schemas
+	`select 'schema ' || nspname
+from pg_namespace
+where nspname not in ('pg_catalog', 'information_schema', 'pg_toast') and nspname not like 'pg_temp%' and nspname not like 'pg_toast_temp%'`,
+
+	// columns
+	`select 'column ' || table_schema || '.' || table_name || '.' || column_name || ' ' || data_type
+           || case when is_nullable = 'NO' then ' not null' else '' end
+           || coalesce(' default ' || column_default, '')
+from information_schema.columns
+where table_schema not in ('pg_catalog', 'information_schema')
+  and table_name <> 'goose_db_version'`,
+
+	// constraints
+	`select 'constraint ' || n.nspname || '.' || t.relname || '.' || c.conname || ' ' || pg_get_constraintdef(c.oid)
+from pg_constraint c
+         join pg_class t on t.oid = c.conrelid
+         join pg_namespace n on n.oid = t.relnamespace
+where n.nspname not in ('pg_catalog', 'information_schema')
+  and t.relname <> 'goose_db_version'`,
+
+	// indexes
+	`select 'index ' || indexdef
+from pg_indexes
+where schemaname not in ('pg_catalog', 'information_schema')
+  and tablename <> 'goose_db_version'`,
+
+	// sequences
+	`select 'sequence ' || sequence_schema || '.' || sequence_name || ' ' || data_type
+from information_schema.sequences
+where sequence_name not like 'goose_db_version%'`,
+}
+
+// snapshotSchema returns a sorted description of the schemas, tables, columns, constraints, indexes and sequences in
+// the database, which can be compared with diffLines
+func snapshotSchema(ctx context.Context, db *sql.DB) ([]string, error) {
+	lines := make([]string, 0)
+	for _, query := range schemaSnapshotQueries {
+		rows, err := db.QueryContext(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var line string
+			if err := rows.Scan(&line); err != nil {
+				safeClose(rows)
+				return nil, err
+			}
+			lines = append(lines, line)
+		}
+		safeClose(rows)
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(lines)
+	return lines, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// createScratchDatabase creates an empty database next to the one in connectionString and returns a connection string
+// for it, along with a function that drops it again
+func createScratchDatabase(ctx context.Context, connectionString, prefix string) (string, func(), error) {
+	return createDatabase(ctx, connectionString, fmt.Sprintf("%s_%d", prefix, time.Now().UnixNano()), "")
+}
+
+// createDatabase creates the named database, copied from template when it is not empty, and returns a connection
+// string for it along with a function that drops it again
+func createDatabase(ctx context.Context, connectionString, name, template string) (string, func(), error) {
+	admin, err := sql.Open(driverName, connectionString)
+	if err != nil {
+		return "", nil, err
+	}
+
+	create := "create database " + pgx.Identifier{name}.Sanitize()
+	if template != "" {
+		create += " template " + pgx.Identifier{template}.Sanitize()
+	}
+	if _, err := admin.ExecContext(ctx, create); err != nil {
+		safeClose(admin)
+		return "", nil, err
+	}
+
+	drop := func() {
+		// force disconnects anything still using it, e.g. connections left in a pool
+		_, _ = admin.ExecContext(context.Background(), "drop database if exists "+pgx.Identifier{name}.Sanitize()+" with (force)")
+		safeClose(admin)
+	}
+	dsn, err := withDatabaseName(connectionString, name)
+	if err != nil {
+		drop()
+		return "", nil, err
+	}
+	return dsn, drop, nil
+}
+
+// withDatabaseName returns connectionString pointing at another database, for both url and keyword/value forms
+func withDatabaseName(connectionString, name string) (string, error) {
+	if strings.HasPrefix(connectionString, "postgres://") || strings.HasPrefix(connectionString, "postgresql://") {
+		u, err := url.Parse(connectionString)
+		if err != nil {
+			return "", err
+		}
+		u.Path = "/" + name
+		return u.String(), nil
+	}
+	// later keywords override earlier ones
+	return connectionString + " dbname=" + name, nil
+}
+
+// withParameter returns connectionString with a run-time parameter such as timezone or statement_timeout set, for both
+// url and keyword/value forms
+func withParameter(connectionString, key, value string) (string, error) {
+	if strings.HasPrefix(connectionString, "postgres://") || strings.HasPrefix(connectionString, "postgresql://") {
+		u, err := url.Parse(connectionString)
+		if err != nil {
+			return "", err
+		}
+		query := u.Query()
+		query.Set(key, value)
+		u.RawQuery = query.Encode()
+		return u.String(), nil
+	}
+	// later keywords override earlier ones, the value is quoted in case it has spaces
+	return connectionString + " " + key + "='" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'", nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"github.com/jackc/pgx/v5/pgconn"
+	"testing"
+)
+
+func TestWithParameter(t *testing.T) {
+	tests := []struct {
+		name, dsn, key, value string
+	}{
+		{"keyword/value", "user=u password=p host=localhost dbname=d", "timezone", "America/New_York"},
+		{"keyword/value quoted", "user=u password=p host=localhost dbname=d", "application_name", `it's a \ test`},
+		{"keyword/value overrides", "user=u host=localhost timezone=UTC", "timezone", "Asia/Kolkata"},
+		{"url", "postgres://u:p@localhost:5433/d?sslmode=disable", "timezone", "Asia/Kolkata"},
+		{"url overrides", "postgresql://u:p@localhost/d?statement_timeout=5", "statement_timeout", "200"},
+		{"url without query", "postgres://u:p@localhost/d", "statement_timeout", "200"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dsn, err := withParameter(tt.dsn, tt.key, tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			config, err := pgconn.ParseConfig(dsn)
+			if err != nil {
+				t.Fatalf("%q does not parse: %v", dsn, err)
+			}
+			if got := config.RuntimeParams[tt.key]; got != tt.value {
+				t.Errorf("%s is %q in %q, want %q", tt.key, got, dsn, tt.value)
+			}
+			if config.Host != "localhost" || config.User != "u" {
+				t.Errorf("%q lost the host or user", dsn)
+			}
+		})
+	}
+}
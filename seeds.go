@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// seed datasets live in seeds/<dataset>/ and are applied in file name order. Files can be:
+//   - .sql, run as is
+//   - .csv, with a header row of column names, empty values are null
+//   - .json, an array of objects keyed by column name
+//
+// csv and json files are inserted into the test schema table named by the file, ignoring any leading number, so
+// 02_sample_table.csv goes into test.sample_table.
+//
+//go:embed seeds
+var embedSeeds embed.FS
+
+// seedTableName strips the ordering prefix and extension from a seed file name
+var seedTableName = regexp.MustCompile(`^(?:\d+_)?([a-z_][a-z0-9_]*)\.(?:csv|json)$`)
+
+// seedColumnName limits csv and json columns to plain identifiers, since they are put into the insert as is
+var seedColumnName = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// seedDatasetsFor returns the datasets to apply, from the command line if -seeds was given, otherwise from the
+// migrations config for the environment
+func seedDatasetsFor(s migrationSettings) ([]string, error) {
+	if flagWasSet("seeds") {
+		datasets := make([]string, 0)
+		for _, d := range strings.Split(s.seeds, ",") {
+			if d = strings.TrimSpace(d); d != "" {
+				datasets = append(datasets, d)
+			}
+		}
+		return datasets, nil
+	}
+	config, err := readMigrationConfig(s.configPath)
+	if err != nil {
+		return nil, err
+	}
+	return config.Environments[s.env].Seeds, nil
+}
+
+// applySeeds applies every dataset that has not been applied yet. Each file is recorded in public.seed_history with a
+// checksum, so running it again is a no op, and a file that changed since it was applied is reported but not rerun.
+// The history is kept out of the test schema, like goose_db_version, so the migrations can still drop it, and records
+// which test schema the file went into so it is applied again once the migrations recreate the schema.
+func applySeeds(ctx context.Context, db *sql.DB, datasets []string) error {
+	// databases seeded before it was moved have it in the test schema
+	if _, err := db.ExecContext(ctx, `do $$
+begin
+    if to_regclass('test.seed_history') is not null and to_regclass('public.seed_history') is null then
+        alter table test.seed_history set schema public;
+    end if;
+end
+$$`); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, `create table if not exists public.seed_history
+(
+    dataset    text        not null,
+    file       text        not null,
+    checksum   text        not null,
+    applied_at timestamptz not null default now(),
+    schema_oid oid,
+    primary key (dataset, file)
+)`); err != nil {
+		return err
+	}
+	// rows from before schema_oid was recorded are taken to be for the schema there is now, the ones for a schema that
+	// has since been dropped, e.g. by migrate reset, are forgotten along with the rows they inserted
+	if _, err := db.ExecContext(ctx, `alter table public.seed_history add column if not exists schema_oid oid`); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, `update public.seed_history set schema_oid = to_regnamespace('test')::oid where schema_oid is null`); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, `delete from public.seed_history where schema_oid is distinct from to_regnamespace('test')::oid`); err != nil {
+		return err
+	}
+
+	for _, dataset := range datasets {
+		entries, err := fs.ReadDir(embedSeeds, path.Join("seeds", dataset))
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("unknown seed dataset %q", dataset)
+		} else if err != nil {
+			return err
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+		for _, entry := range entries {
+			if err := applySeedFile(ctx, db, dataset, entry.Name()); err != nil {
+				return fmt.Errorf("seed %s/%s: %w", dataset, entry.Name(), err)
+			}
+		}
+	}
+	return nil
+}
+
+func applySeedFile(ctx context.Context, db *sql.DB, dataset, file string) error {
+	contents, err := embedSeeds.ReadFile(path.Join("seeds", dataset, file))
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(contents)
+	checksum := hex.EncodeToString(sum[:])
+
+	var applied string
+	err = db.QueryRowContext(ctx, "select checksum from public.seed_history where dataset = $1 and file = $2", dataset, file).Scan(&applied)
+	if err == nil {
+		if applied != checksum {
+			log.Printf("seed %s/%s changed since it was applied, it will not be applied again", dataset, file)
+		}
+		return nil
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	switch path.Ext(file) {
+	case ".sql":
+		_, err = tx.ExecContext(ctx, string(contents))
+	case ".csv":
+		err = insertSeedRows(ctx, tx, file, csvSeedRows(contents))
+	case ".json":
+		err = insertSeedRows(ctx, tx, file, jsonSeedRows(contents))
+	default:
+		return fmt.Errorf("unsupported seed file type %q", path.Ext(file))
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"insert into public.seed_history (dataset, file, checksum, schema_oid) values ($1, $2, $3, to_regnamespace('test')::oid)",
+		dataset, file, checksum,
+	); err != nil {
+		return err
+	}
+	log.Printf("applied seed %s/%s", dataset, file)
+	return tx.Commit()
+}
+
+// seedRows yields the rows of a csv or json seed file as column name to value, nil values are inserted as null
+type seedRows func(yield func(row map[string]any) error) error
+
+func csvSeedRows(contents []byte) seedRows {
+	return func(yield func(row map[string]any) error) error {
+		r := csv.NewReader(bytes.NewReader(contents))
+		header, err := r.Read()
+		if err != nil {
+			return err
+		}
+		for {
+			record, err := r.Read()
+			if errors.Is(err, io.EOF) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			row := make(map[string]any, len(header))
+			for i, column := range header {
+				if record[i] != "" {
+					row[column] = record[i]
+				} else {
+					row[column] = nil
+				}
+			}
+			if err := yield(row); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func jsonSeedRows(contents []byte) seedRows {
+	return func(yield func(row map[string]any) error) error {
+		d := json.NewDecoder(bytes.NewReader(contents))
+		d.UseNumber()
+		var rows []map[string]any
+		if err := d.Decode(&rows); err != nil {
+			return err
+		}
+		for _, row := range rows {
+			for column, value := range row {
+				switch v := value.(type) {
+				case json.Number:
+					// sent as text and converted by postgres to the column type
+					row[column] = v.String()
+				case map[string]any, []any:
+					b, _ := json.Marshal(v)
+					row[column] = string(b)
+				}
+			}
+			if err := yield(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// insertSeedRows inserts every row into the table named by the seed file
+func insertSeedRows(ctx context.Context, tx *sql.Tx, file string, rows seedRows) error {
+	match := seedTableName.FindStringSubmatch(file)
+	if match == nil {
+		return fmt.Errorf("%q does not name a table", file)
+	}
+	table := "test." + match[1]
+
+	return rows(func(row map[string]any) error {
+		columns := make([]string, 0, len(row))
+		for column := range row {
+			if !seedColumnName.MatchString(column) {
+				return fmt.Errorf("invalid column name %q", column)
+			}
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+
+		placeholders := make([]string, 0, len(columns))
+		args := make([]any, 0, len(columns))
+		for i, column := range columns {
+			placeholders = append(placeholders, fmt.Sprintf("$%d", i+1))
+			args = append(args, row[column])
+		}
+		_, err := tx.ExecContext(ctx,
+			fmt.Sprintf("insert into %s (%s) values (%s)", table, strings.Join(columns, ", "), strings.Join(placeholders, ", ")),
+			args...,
+		)
+		return err
+	})
+}
@@ -0,0 +1,3 @@
+insert into test.sample_table (name, description, int_example)
+select 'load test ' || i, case when i % 3 = 0 then null else 'load test description ' || i end, i % 100
+from generate_series(1, 10000) as i;
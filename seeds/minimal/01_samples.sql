@@ -0,0 +1,8 @@
+-- written so databases that got these rows from the init migration, before they were moved here, are not duplicated
+insert into test.sample_table (name, description, int_example)
+select 'first', 'with description', 1
+where not exists (select 1 from test.sample_table where name = 'first');
+
+insert into test.sample_table (name)
+select 'just name'
+where not exists (select 1 from test.sample_table where name = 'just name');
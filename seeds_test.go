@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+	"testing"
+)
+
+// TestSeedsAfterReset checks the seeds are applied again once migrate reset dropped the rows they inserted
+func TestSeedsAfterReset(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open(driverName, newTestDatabase(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(db)
+	if err := setMigrationSource(db); err != nil {
+		t.Fatal(err)
+	}
+	datasets, err := seedDatasetsFor(migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count := func() int {
+		var n int
+		if err := db.QueryRowContext(ctx, "select count(*) from test.sample_table").Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	seeded := count()
+	if seeded == 0 {
+		t.Fatalf("the template has no seeded rows for %v", datasets)
+	}
+
+	if err := goose.Reset(db, migrationsDir); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	if err := goose.Up(db, migrationsDir); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	if err := applySeeds(ctx, db, datasets); err != nil {
+		t.Fatalf("seeds: %v", err)
+	}
+	if got := count(); got != seeded {
+		t.Errorf("%d rows after reset and seeding again, want the %d seeded at first", got, seeded)
+	}
+
+	// and applying them once more changes nothing
+	if err := applySeeds(ctx, db, datasets); err != nil {
+		t.Fatalf("seeds again: %v", err)
+	}
+	if got := count(); got != seeded {
+		t.Errorf("%d rows after seeding twice, want %d", got, seeded)
+	}
+}
@@ -31,6 +31,7 @@ type SampleTable struct {
 	CreatedAt   time.Time   `boil:"created_at" json:"created_at" toml:"created_at" yaml:"created_at"`
 	UpdatedAt   time.Time   `boil:"updated_at" json:"updated_at" toml:"updated_at" yaml:"updated_at"`
 	DeletedAt   null.Time   `boil:"deleted_at" json:"deleted_at,omitempty" toml:"deleted_at" yaml:"deleted_at,omitempty"`
+	Slug        null.String `boil:"slug" json:"slug,omitempty" toml:"slug" yaml:"slug,omitempty"`
 
 	R *sampleTableR `boil:"-" json:"-" toml:"-" yaml:"-"`
 	L sampleTableL  `boil:"-" json:"-" toml:"-" yaml:"-"`
@@ -44,6 +45,7 @@ var SampleTableColumns = struct {
 	CreatedAt   string
 	UpdatedAt   string
 	DeletedAt   string
+	Slug        string
 }{
 	ID:          "id",
 	Name:        "name",
@@ -52,6 +54,7 @@ var SampleTableColumns = struct {
 	CreatedAt:   "created_at",
 	UpdatedAt:   "updated_at",
 	DeletedAt:   "deleted_at",
+	Slug:        "slug",
 }
 
 var SampleTableTableColumns = struct {
@@ -62,6 +65,7 @@ var SampleTableTableColumns = struct {
 	CreatedAt   string
 	UpdatedAt   string
 	DeletedAt   string
+	Slug        string
 }{
 	ID:          "sample_table.id",
 	Name:        "sample_table.name",
@@ -70,6 +74,7 @@ var SampleTableTableColumns = struct {
 	CreatedAt:   "sample_table.created_at",
 	UpdatedAt:   "sample_table.updated_at",
 	DeletedAt:   "sample_table.deleted_at",
+	Slug:        "sample_table.slug",
 }
 
 // Generated where
@@ -265,6 +270,7 @@ var SampleTableWhere = struct {
 	CreatedAt   whereHelpertime_Time
 	UpdatedAt   whereHelpertime_Time
 	DeletedAt   whereHelpernull_Time
+	Slug        whereHelpernull_String
 }{
 	ID:          whereHelperint{field: "\"test\".\"sample_table\".\"id\""},
 	Name:        whereHelperstring{field: "\"test\".\"sample_table\".\"name\""},
@@ -273,6 +279,7 @@ var SampleTableWhere = struct {
 	CreatedAt:   whereHelpertime_Time{field: "\"test\".\"sample_table\".\"created_at\""},
 	UpdatedAt:   whereHelpertime_Time{field: "\"test\".\"sample_table\".\"updated_at\""},
 	DeletedAt:   whereHelpernull_Time{field: "\"test\".\"sample_table\".\"deleted_at\""},
+	Slug:        whereHelpernull_String{field: "\"test\".\"sample_table\".\"slug\""},
 }
 
 // SampleTableRels is where relationship names are stored.
@@ -292,9 +299,9 @@ func (*sampleTableR) NewStruct() *sampleTableR {
 type sampleTableL struct{}
 
 var (
-	sampleTableAllColumns            = []string{"id", "name", "description", "int_example", "created_at", "updated_at", "deleted_at"}
+	sampleTableAllColumns            = []string{"id", "name", "description", "int_example", "created_at", "updated_at", "deleted_at", "slug"}
 	sampleTableColumnsWithoutDefault = []string{"name"}
-	sampleTableColumnsWithDefault    = []string{"id", "description", "int_example", "created_at", "updated_at", "deleted_at"}
+	sampleTableColumnsWithDefault    = []string{"id", "description", "int_example", "created_at", "updated_at", "deleted_at", "slug"}
 	sampleTablePrimaryKeyColumns     = []string{"id"}
 	sampleTableGeneratedColumns      = []string{}
 )
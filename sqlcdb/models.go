@@ -9,11 +9,12 @@ import (
 )
 
 type TestSampleTable struct {
-	ID          int32            `json:"id"`
-	Name        string           `json:"name"`
-	Description *string          `json:"description"`
-	IntExample  *int32           `json:"intExample"`
-	CreatedAt   pgtype.Timestamp `json:"createdAt"`
-	UpdatedAt   pgtype.Timestamp `json:"updatedAt"`
-	DeletedAt   pgtype.Timestamp `json:"deletedAt"`
+	ID          int32              `json:"id"`
+	Name        string             `json:"name"`
+	Description *string            `json:"description"`
+	IntExample  *int32             `json:"intExample"`
+	CreatedAt   pgtype.Timestamptz `json:"createdAt"`
+	UpdatedAt   pgtype.Timestamptz `json:"updatedAt"`
+	DeletedAt   pgtype.Timestamptz `json:"deletedAt"`
+	Slug        *string            `json:"slug"`
 }
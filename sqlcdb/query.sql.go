@@ -27,7 +27,7 @@ func (q *Queries) CreateSampleNoReturn(ctx context.Context, arg CreateSampleNoRe
 
 const createSampleWithReturn = `-- name: CreateSampleWithReturn :one
 insert into test.sample_table (name, description, int_example)
-values ($1, $2, $3) returning id, name, description, int_example, created_at, updated_at, deleted_at
+values ($1, $2, $3) returning id, name, description, int_example, created_at, updated_at, deleted_at, slug
 `
 
 type CreateSampleWithReturnParams struct {
@@ -47,12 +47,13 @@ func (q *Queries) CreateSampleWithReturn(ctx context.Context, arg CreateSampleWi
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
+		&i.Slug,
 	)
 	return i, err
 }
 
 const getAllSamples = `-- name: GetAllSamples :many
-select id, name, description, int_example, created_at, updated_at, deleted_at from test.sample_table
+select id, name, description, int_example, created_at, updated_at, deleted_at, slug from test.sample_table
 `
 
 func (q *Queries) GetAllSamples(ctx context.Context) ([]TestSampleTable, error) {
@@ -72,6 +73,7 @@ func (q *Queries) GetAllSamples(ctx context.Context) ([]TestSampleTable, error)
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.DeletedAt,
+			&i.Slug,
 		); err != nil {
 			return nil, err
 		}
@@ -137,7 +139,7 @@ func (q *Queries) GetIdDescriptions(ctx context.Context) ([]GetIdDescriptionsRow
 }
 
 const getSampleByID = `-- name: GetSampleByID :one
-select id, name, description, int_example, created_at, updated_at, deleted_at from test.sample_table where id = $1
+select id, name, description, int_example, created_at, updated_at, deleted_at, slug from test.sample_table where id = $1
 `
 
 func (q *Queries) GetSampleByID(ctx context.Context, id int32) (TestSampleTable, error) {
@@ -151,6 +153,44 @@ func (q *Queries) GetSampleByID(ctx context.Context, id int32) (TestSampleTable,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
+		&i.Slug,
 	)
 	return i, err
 }
+
+const setSampleIntExample = `-- name: SetSampleIntExample :exec
+update test.sample_table set int_example = $1, updated_at = now() where id = $2
+`
+
+type SetSampleIntExampleParams struct {
+	IntExample *int32 `json:"intExample"`
+	ID         int32  `json:"id"`
+}
+
+func (q *Queries) SetSampleIntExample(ctx context.Context, arg SetSampleIntExampleParams) error {
+	_, err := q.db.Exec(ctx, setSampleIntExample, arg.IntExample, arg.ID)
+	return err
+}
+
+const sleep = `-- name: Sleep :exec
+select pg_sleep($1)
+`
+
+func (q *Queries) Sleep(ctx context.Context, pgSleep float64) error {
+	_, err := q.db.Exec(ctx, sleep, pgSleep)
+	return err
+}
+
+const updateSampleName = `-- name: UpdateSampleName :exec
+update test.sample_table set name = $1, updated_at = now() where id = $2
+`
+
+type UpdateSampleNameParams struct {
+	Name string `json:"name"`
+	ID   int32  `json:"id"`
+}
+
+func (q *Queries) UpdateSampleName(ctx context.Context, arg UpdateSampleNameParams) error {
+	_, err := q.db.Exec(ctx, updateSampleName, arg.Name, arg.ID)
+	return err
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"embed"
+	"flag"
+	"github.com/jmoiron/sqlx"
+	"github.com/pressly/goose/v3"
+	"github.com/volatiletech/null/v8"
+	"github.com/volatiletech/sqlboiler/v4/boil"
+	gomigrations "go-orm-test/migrations"
+	sqlitesqlb "go-orm-test/sqlite/sqlbdb"
+	sqlitesqlc "go-orm-test/sqlite/sqlcdb"
+	gormsqlite "gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+	"log"
+	"modernc.org/sqlite"
+	"os"
+	"path/filepath"
+)
+
+// sqliteMigrationsDir holds the sqlite translation of the migrations, the go migrations are shared with postgres
+const sqliteMigrationsDir = "sqlite/migrations"
+
+//go:embed sqlite/migrations/*.sql
+var embedSQLiteMigrations embed.FS
+
+// sqliteConnector opens connections to a sqlite file with a second file attached as "test", so the schema qualified
+// table names the postgres code uses work unchanged
+type sqliteConnector struct {
+	dsn      string
+	testFile string
+}
+
+func (c sqliteConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Driver().Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	// attaching only lasts for the connection, so every new one in the pool does it
+	_, err = conn.(driver.ExecerContext).ExecContext(ctx, "attach database ? as test", []driver.NamedValue{{Ordinal: 1, Value: c.testFile}})
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (c sqliteConnector) Driver() driver.Driver {
+	return &sqlite.Driver{}
+}
+
+// openSQLite opens a pool on the sqlite database in dir, creating its files when they don't exist
+func openSQLite(dir string) *sql.DB {
+	return sql.OpenDB(sqliteConnector{
+		dsn:      "file:" + filepath.Join(dir, "main.db") + "?_pragma=busy_timeout(5000)",
+		testFile: filepath.Join(dir, "test.db"),
+	})
+}
+
+// openSQLiteConnections connects every library to the sqlite database in dir. sqlc has no connection of its own as
+// its sqlite code is generated for database/sql, the returned queries use the custom pool.
+func openSQLiteConnections(ctx context.Context, dir string) (*connections, *sqlitesqlc.Queries, error) {
+	c := &connections{
+		custom:    openSQLite(dir),
+		sqlboiler: openSQLite(dir),
+	}
+	if err := c.custom.PingContext(ctx); err != nil {
+		c.Close()
+		return nil, nil, err
+	}
+
+	// sqlx needs the driver name to pick the bind variable style, modernc registers itself as "sqlite" which sqlx
+	// doesn't know
+	c.sqlx = sqlx.NewDb(openSQLite(dir), "sqlite3")
+
+	var err error
+	gormDB := openSQLite(dir)
+	c.gorm, err = gorm.Open(gormsqlite.Dialector{Conn: gormDB}, &gorm.Config{
+		NamingStrategy: schema.NamingStrategy{
+			TablePrefix:   "test.",
+			SingularTable: true,
+		},
+	})
+	if err != nil {
+		safeClose(gormDB)
+		c.Close()
+		return nil, nil, err
+	}
+
+	return c, sqlitesqlc.New(c.custom), nil
+}
+
+// migrateSQLite applies the sqlite migrations and the go migrations to db
+func migrateSQLite(db *sql.DB) error {
+	if err := goose.SetDialect("sqlite3"); err != nil {
+		return err
+	}
+	goose.SetBaseFS(embedSQLiteMigrations)
+	if err := gomigrations.SetDB(db, "sqlite3"); err != nil {
+		return err
+	}
+	return goose.Up(db, sqliteMigrationsDir)
+}
+
+// sqliteLibraries are the libraries run against sqlite. custom, sqlx and gorm run the same code as against postgres,
+// sqlc and sqlboiler run code generated from sqlite/schema.sql.
+func sqliteLibraries(queries *sqlitesqlc.Queries) []libraryOps {
+	ops := make([]libraryOps, 0, len(libraries))
+	for _, l := range libraries {
+		switch l.library {
+		case "custom", "sqlx", "gorm":
+			l.note = "same code as postgres"
+			ops = append(ops, l)
+		}
+	}
+	return append(ops,
+		libraryOps{
+			library: "sqlc",
+			note:    "generated from sqlite/query.sql",
+			insert: func(ctx context.Context, _ *connections, s Sample) error {
+				return queries.CreateSampleNoReturn(ctx, sqlitesqlc.CreateSampleNoReturnParams{
+					Name:        s.Name,
+					Description: nullString(s.Description),
+					IntExample:  nullInt64(s.IntExample),
+				})
+			},
+			insertReturning: func(ctx context.Context, _ *connections, s Sample) (Sample, error) {
+				sc, err := queries.CreateSampleWithReturn(ctx, sqlitesqlc.CreateSampleWithReturnParams{
+					Name:        s.Name,
+					Description: nullString(s.Description),
+					IntExample:  nullInt64(s.IntExample),
+				})
+				return sampleFromSQLiteSqlc(sc), err
+			},
+			getByID: func(ctx context.Context, _ *connections, id int) (Sample, error) {
+				sc, err := queries.GetSampleByID(ctx, int64(id))
+				return sampleFromSQLiteSqlc(sc), err
+			},
+			listAll: func(ctx context.Context, _ *connections) ([]Sample, error) {
+				sqlcSamples, err := queries.GetAllSamples(ctx)
+				return mapSamples(sqlcSamples, sampleFromSQLiteSqlc), err
+			},
+		},
+		libraryOps{
+			library: "sqlboiler",
+			note:    "generated from sqlite/schema.sql",
+			insert: func(ctx context.Context, c *connections, s Sample) error {
+				sb := sqliteSqlboilerSample(s)
+				return sb.Insert(ctx, c.sqlboiler, boil.Infer())
+			},
+			insertReturning: func(ctx context.Context, c *connections, s Sample) (Sample, error) {
+				sb := sqliteSqlboilerSample(s)
+				err := sb.Insert(ctx, c.sqlboiler, boil.Infer())
+				return sampleFromSQLiteSqlboiler(sb), err
+			},
+			getByID: func(ctx context.Context, c *connections, id int) (Sample, error) {
+				sb, err := sqlitesqlb.FindSampleTable(ctx, c.sqlboiler, int64(id))
+				if err != nil {
+					return Sample{}, err
+				}
+				return sampleFromSQLiteSqlboiler(sb), nil
+			},
+			listAll: func(ctx context.Context, c *connections) ([]Sample, error) {
+				sqlbSamples, err := sqlitesqlb.SampleTables().All(ctx, c.sqlboiler)
+				return mapSamples(sqlbSamples, sampleFromSQLiteSqlboiler), err
+			},
+		},
+	)
+}
+
+func sqliteSqlboilerSample(s Sample) *sqlitesqlb.SampleTable {
+	return &sqlitesqlb.SampleTable{
+		Name:        s.Name,
+		Description: null.StringFromPtr(s.Description),
+		IntExample:  null.Int64FromPtr(int64Ptr(s.IntExample)),
+	}
+}
+
+func int64Ptr(i *int) *int64 {
+	if i == nil {
+		return nil
+	}
+	return ptr(int64(*i))
+}
+
+func nullString(s *string) sql.NullString {
+	if s == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: *s, Valid: true}
+}
+
+func nullInt64(i *int) sql.NullInt64 {
+	if i == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(*i), Valid: true}
+}
+
+// runSQLite runs every scenario with every library against an in-process sqlite database, then reports which
+// operations failed and where the libraries' results differ. It returns 1 if any failed or differ.
+func runSQLite(ctx context.Context, args []string) int {
+	flags := flag.NewFlagSet("sqlite", flag.ExitOnError)
+	dir := flags.String("dir", "", "directory for the database files, a temporary one removed afterwards when empty")
+	report := portabilityFlags(flags)
+	_ = flags.Parse(args)
+
+	if *dir == "" {
+		tmp, err := os.MkdirTemp("", "go-orm-test-sqlite-")
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer func() { _ = os.RemoveAll(tmp) }()
+		*dir = tmp
+	}
+
+	conns, queries, err := openSQLiteConnections(ctx, *dir)
+	if err != nil {
+		log.Fatalf("unable to open sqlite: %v", err)
+	}
+	defer conns.Close()
+	if err := migrateSQLite(conns.custom); err != nil {
+		log.Fatalf("unable to migrate sqlite: %v", err)
+	}
+
+	return runPortability(ctx, conns, sqliteLibraries(queries), report)
+}
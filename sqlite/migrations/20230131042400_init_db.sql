@@ -0,0 +1,14 @@
+-- +goose Up
+create table test.sample_table
+(
+    id          integer  not null primary key autoincrement,
+    name        text     not null,
+    description text,
+    int_example integer,
+    created_at  datetime not null default current_timestamp,
+    updated_at  datetime not null default current_timestamp,
+    deleted_at  datetime
+);
+
+-- +goose Down
+drop table test.sample_table;
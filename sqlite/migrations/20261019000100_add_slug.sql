@@ -0,0 +1,6 @@
+-- +goose Up
+-- filled in by the go migration after this one
+alter table test.sample_table add column slug text;
+
+-- +goose Down
+alter table test.sample_table drop column slug;
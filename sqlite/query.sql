@@ -0,0 +1,20 @@
+
+-- name: CreateSampleNoReturn :exec
+insert into sample_table (name, description, int_example)
+values (?, ?, ?);
+
+-- name: GetAllSamples :many
+select * from sample_table;
+
+-- name: CreateSampleWithReturn :one
+insert into sample_table (name, description, int_example)
+values (?, ?, ?) returning *;
+
+-- name: GetDescriptions :many
+select description from sample_table;
+
+-- name: GetIdDescriptions :many
+select id, description from sample_table;
+
+-- name: GetSampleByID :one
+select * from sample_table where id = ?;
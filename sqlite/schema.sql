@@ -0,0 +1,16 @@
+-- The schema sqlc and sqlboiler generate the sqlite packages from, kept in step with sqlite/migrations by hand.
+-- sqlite has no schemas, the migrations create the table in a database attached as "test" and the generated code
+-- refers to it unqualified, which sqlite resolves to the attached database. Timestamps are datetime as sqlboiler's
+-- sqlite driver only maps date and datetime columns to time.Time.
+
+create table sample_table
+(
+    id          integer  not null primary key autoincrement,
+    name        text     not null,
+    description text,
+    int_example integer,
+    created_at  datetime not null default current_timestamp,
+    updated_at  datetime not null default current_timestamp,
+    deleted_at  datetime,
+    slug        text
+);
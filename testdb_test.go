@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/pressly/goose/v3"
+	"log"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testDSNEnv names the environment variable holding the server tests create their databases on, the docker-compose
+// one is used when it is not set
+const testDSNEnv = "PLAYGROUND_TEST_DSN"
+
+// testTemplate is the database every test database is cloned from, migrated once by TestMain
+var testTemplate struct {
+	dsn  string
+	name string
+	drop func()
+	// skip is why tests needing a database are skipped, set when no server was given and the default one is down
+	skip string
+}
+
+// testDatabases counts the databases cloned from testTemplate, to name them
+var testDatabases atomic.Int64
+
+// TestMain migrates the template database the tests clone theirs from, and drops it once they are done
+func TestMain(m *testing.M) {
+	if err := createTestTemplate(context.Background()); err != nil {
+		log.Fatalf("unable to create template database: %v", err)
+	}
+	code := m.Run()
+	if testTemplate.drop != nil {
+		testTemplate.drop()
+	}
+	os.Exit(code)
+}
+
+// createTestTemplate creates and migrates the template database. Without PLAYGROUND_TEST_DSN a default server that
+// isn't running only skips the tests needing a database, so the others can run anywhere.
+func createTestTemplate(ctx context.Context) error {
+	dsn, given := os.LookupEnv(testDSNEnv)
+	if !given {
+		dsn = defaultConnectionString
+		if err := pingServer(ctx, dsn); err != nil {
+			testTemplate.skip = fmt.Sprintf("no postgres to test against, start it or set %s: %v", testDSNEnv, err)
+			return nil
+		}
+	}
+
+	// tests don't go through main, so the settings its flags would fill in are set here
+	migrations = migrationSettings{env: envOr("PLAYGROUND_TEST_ENV", "test"), configPath: "migrations.json"}
+
+	// the pid alone can repeat across machines sharing a server, or a test binary run again right after a crash
+	name := fmt.Sprintf("playground_template_%d_%d", os.Getpid(), time.Now().UnixNano())
+	drop, err := migrateTestTemplate(ctx, dsn, name)
+	if err != nil {
+		return err
+	}
+	testTemplate.dsn, testTemplate.name, testTemplate.drop = dsn, name, drop
+	return nil
+}
+
+// pingServer checks something accepts connections at dsn, without waiting for it to come up
+func pingServer(ctx context.Context, dsn string) error {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return err
+	}
+	defer safeClose(db)
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	return db.PingContext(ctx)
+}
+
+// migrateTestTemplate creates the template database with the migrations and seeds of the test environment applied
+func migrateTestTemplate(ctx context.Context, dsn, name string) (func(), error) {
+	templateDSN, drop, err := createDatabase(ctx, dsn, name, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// postgres refuses to copy a database something is connected to, so this connection is closed before returning
+	db, err := sql.Open(driverName, templateDSN)
+	if err != nil {
+		drop()
+		return nil, err
+	}
+	defer safeClose(db)
+	if err := setMigrationSource(db); err != nil {
+		drop()
+		return nil, err
+	}
+	if err := goose.Up(db, migrationsDir); err != nil {
+		drop()
+		return nil, err
+	}
+	datasets, err := seedDatasetsFor(migrations)
+	if err != nil {
+		drop()
+		return nil, err
+	}
+	if err := applySeeds(ctx, db, datasets); err != nil {
+		drop()
+		return nil, err
+	}
+	return drop, nil
+}
+
+// newTestDatabase creates a database for t cloned from the migrated template and returns its connection string. The
+// database is dropped when t finishes, so tests using it can call t.Parallel. t is skipped when there is no server.
+func newTestDatabase(t testing.TB) string {
+	t.Helper()
+	if testTemplate.skip != "" {
+		t.Skip(testTemplate.skip)
+	}
+
+	name := fmt.Sprintf("%s_%d", testTemplate.name, testDatabases.Add(1))
+	dsn, drop, err := createDatabase(context.Background(), testTemplate.dsn, name, testTemplate.name)
+	if err != nil {
+		t.Fatalf("unable to create test database: %v", err)
+	}
+	t.Cleanup(drop)
+	return dsn
+}
+
+// newTestConnections creates a database for t like newTestDatabase and returns connections to it for every library,
+// closed before the database is dropped
+func newTestConnections(t testing.TB) *connections {
+	t.Helper()
+	dsn := newTestDatabase(t)
+
+	// the pools are sized for the test environment, main isn't there to load the pool config
+	pool, err := loadPoolConfig("pool.json", migrations.env)
+	if err != nil {
+		t.Fatalf("unable to read pool config: %v", err)
+	}
+	c, err := openConnections(context.Background(), dsn, withPool(pool))
+	if err != nil {
+		t.Fatalf("unable to connect to test database: %v", err)
+	}
+	// cleanups run last added first, so this runs before the drop
+	t.Cleanup(c.Close)
+	return c
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// tzResult is the created_at of one row written by one library and read back by another
+type tzResult struct {
+	sessionZone string
+	processZone string
+	writer      string
+	reader      string
+	got         time.Time
+	shift       time.Duration // zero when got is within tolerance of the time of the insert
+	err         error
+}
+
+// runTimezoneMatrix writes a row with every library and reads it back with every library under each combination of
+// session TimeZone and process TZ, reporting any library that returns a different instant than the one written.
+// It returns the exit code for the process.
+func runTimezoneMatrix(ctx context.Context, args []string) int {
+	flags := flag.NewFlagSet("tz", flag.ExitOnError)
+	dsn := flags.String("dsn", connectionString, "connection string")
+	sessionZones := flags.String("session-zones", "UTC,America/New_York,Asia/Kolkata", "comma separated session TimeZone values")
+	processZones := flags.String("process-zones", "UTC,America/Los_Angeles,Asia/Tokyo", "comma separated process TZ values")
+	tolerance := flags.Duration("tolerance", 5*time.Second, "allowed difference between the app and database clocks")
+	_ = flags.Parse(args)
+
+	conns, err := openConnections(ctx, *dsn)
+	if err != nil {
+		log.Fatalf("unable to connect: %v", err)
+	}
+	migrateWithGoose(conns.custom)
+	var columnType string
+	_ = conns.custom.QueryRowContext(ctx,
+		"select data_type from information_schema.columns where table_schema = 'test' and table_name = 'sample_table' and column_name = 'created_at'",
+	).Scan(&columnType)
+	conns.Close()
+
+	originalLocal := time.Local
+	defer func() { time.Local = originalLocal }()
+
+	results := make([]tzResult, 0)
+	for _, processZone := range strings.Split(*processZones, ",") {
+		loc, err := time.LoadLocation(processZone)
+		if err != nil {
+			log.Fatalf("invalid process zone %q: %v", processZone, err)
+		}
+		// equivalent to running the process with TZ set, which is what gorm's default NowFunc uses
+		time.Local = loc
+
+		for _, sessionZone := range strings.Split(*sessionZones, ",") {
+			r, err := timezoneScenario(ctx, *dsn, sessionZone, *tolerance)
+			if err != nil {
+				log.Fatalf("session zone %s, process zone %s: %v", sessionZone, processZone, err)
+			}
+			for i := range r {
+				r[i].processZone = processZone
+			}
+			results = append(results, r...)
+		}
+	}
+
+	fmt.Printf("created_at column type: %s\n", columnType)
+	return printTimezoneResults(results)
+}
+
+// timezoneScenario runs every writer and reader with connections using the given session TimeZone
+func timezoneScenario(ctx context.Context, dsn, sessionZone string, tolerance time.Duration) ([]tzResult, error) {
+	zoned, err := withParameter(dsn, "timezone", sessionZone)
+	if err != nil {
+		return nil, err
+	}
+	conns, err := openConnections(ctx, zoned)
+	if err != nil {
+		return nil, err
+	}
+	defer conns.Close()
+
+	results := make([]tzResult, 0, len(libraries)*len(libraries))
+	for _, w := range libraries {
+		// created_at is left for the library (gorm's NowFunc, sqlboiler's boil.GetLocation) or the database to fill in
+		before := time.Now()
+		inserted, err := w.insertReturning(ctx, conns, Sample{Name: "tz " + w.library})
+		after := time.Now()
+		if err != nil {
+			return nil, fmt.Errorf("%s insert: %w", w.library, err)
+		}
+
+		for _, r := range libraries {
+			res := tzResult{sessionZone: sessionZone, writer: w.library, reader: r.library}
+			var read Sample
+			read, res.err = r.getByID(ctx, conns, inserted.ID)
+			res.got = read.CreatedAt
+			if res.err == nil {
+				if res.got.Before(before.Add(-tolerance)) {
+					res.shift = res.got.Sub(before).Round(time.Minute)
+				} else if res.got.After(after.Add(tolerance)) {
+					res.shift = res.got.Sub(after).Round(time.Minute)
+				}
+			}
+			results = append(results, res)
+		}
+	}
+	return results, nil
+}
+
+// printTimezoneResults prints every shifted or failed combination and a per library summary, returning 1 if any
+// library shifted a time
+func printTimezoneResults(results []tzResult) int {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "PROCESS TZ\tSESSION TZ\tWRITER\tREADER\tCREATED_AT\tSHIFT")
+
+	shiftedWriters := map[string]int{}
+	shiftedReaders := map[string]int{}
+	failed := false
+	for _, r := range results {
+		switch {
+		case r.err != nil:
+			failed = true
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\terror: %v\t\n", r.processZone, r.sessionZone, r.writer, r.reader, r.err)
+		case r.shift != 0:
+			failed = true
+			shiftedWriters[r.writer]++
+			shiftedReaders[r.reader]++
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", r.processZone, r.sessionZone, r.writer, r.reader, r.got.Format(time.RFC3339), r.shift)
+		}
+	}
+	_ = w.Flush()
+
+	fmt.Println()
+	for _, lib := range libraries {
+		fmt.Printf("%-10s shifted as writer: %3d, as reader: %3d\n", lib.library, shiftedWriters[lib.library], shiftedReaders[lib.library])
+	}
+
+	if failed {
+		return 1
+	}
+	fmt.Println("every library returned the same instant")
+	return 0
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestTimezoneMatrix writes and reads a row with every pair of libraries under each combination of session TimeZone
+// and process TZ, and fails for any that returns a different instant than the one written. It changes time.Local, so
+// it can't run in parallel.
+func TestTimezoneMatrix(t *testing.T) {
+	dsn := newTestDatabase(t)
+	sessionZones := []string{"UTC", "America/New_York", "Asia/Kolkata"}
+	processZones := []string{"UTC", "America/Los_Angeles", "Asia/Tokyo"}
+
+	originalLocal := time.Local
+	t.Cleanup(func() { time.Local = originalLocal })
+	for _, processZone := range processZones {
+		loc, err := time.LoadLocation(processZone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		time.Local = loc
+
+		for _, sessionZone := range sessionZones {
+			results, err := timezoneScenario(context.Background(), dsn, sessionZone, 5*time.Second)
+			if err != nil {
+				t.Fatalf("session zone %s, process zone %s: %v", sessionZone, processZone, err)
+			}
+			for _, r := range results {
+				if r.err != nil {
+					t.Errorf("session zone %s, process zone %s, %s read %s's row: %v", sessionZone, processZone, r.reader, r.writer, r.err)
+				} else if r.shift != 0 {
+					t.Errorf("session zone %s, process zone %s, %s read %s's row shifted by %s", sessionZone, processZone, r.reader, r.writer, r.shift)
+				}
+			}
+		}
+	}
+}
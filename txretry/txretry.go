@@ -0,0 +1,131 @@
+// Package txretry runs serializable transactions, retrying them with exponential backoff when postgres aborts them
+// with a serialization failure (sqlstate 40001) or a deadlock (40P01). There is a runner for every access style:
+// database/sql (custom and sqlboiler), sqlx, gorm and pgx (sqlc).
+package txretry
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jmoiron/sqlx"
+	"gorm.io/gorm"
+	"math/rand"
+	"time"
+)
+
+// Policy controls how a transaction is retried
+type Policy struct {
+	// MaxAttempts is how many times the transaction is run at most, including the first
+	MaxAttempts int
+	// Initial is the wait after the first failure, it doubles after every following one up to Max
+	Initial time.Duration
+	Max     time.Duration
+	// Jitter randomizes every wait by up to this fraction of it, so the transactions that conflicted don't retry
+	// together and conflict again
+	Jitter float64
+	// OnRetry is called before every wait, when set
+	OnRetry func(attempt int, wait time.Duration, err error)
+}
+
+// Default suits short transactions contending on a few rows
+var Default = Policy{
+	MaxAttempts: 10,
+	Initial:     10 * time.Millisecond,
+	Max:         time.Second,
+	Jitter:      0.5,
+}
+
+// Retryable reports whether err aborted a transaction that can succeed when run again: a serialization failure
+// (sqlstate 40001) or a deadlock (40P01)
+func Retryable(err error) bool {
+	// the pgconn errors of pgx v4 and v5 both have SQLState
+	var pgErr interface{ SQLState() string }
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	code := pgErr.SQLState()
+	return code == "40001" || code == "40P01"
+}
+
+// Do calls attempt until it succeeds, returns an error that isn't Retryable, MaxAttempts is reached, or ctx is done.
+// attempt must run the whole transaction, from begin to commit.
+func Do(ctx context.Context, p Policy, attempt func(ctx context.Context) error) error {
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+	backoff := p.Initial
+	for n := 1; ; n++ {
+		err := attempt(ctx)
+		if err == nil || !Retryable(err) {
+			return err
+		}
+		if n >= p.MaxAttempts {
+			return fmt.Errorf("giving up after %d attempts: %w", n, err)
+		}
+
+		wait := backoff + time.Duration((random.Float64()*2-1)*p.Jitter*float64(backoff))
+		if p.OnRetry != nil {
+			p.OnRetry(n, wait, err)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%v: %w", ctx.Err(), err)
+		case <-timer.C:
+		}
+
+		if backoff *= 2; backoff > p.Max {
+			backoff = p.Max
+		}
+	}
+}
+
+// serializable are the options every runner begins its transactions with
+var serializable = &sql.TxOptions{Isolation: sql.LevelSerializable}
+
+// SQL runs fn in a serializable database/sql transaction, retrying it with p
+func SQL(ctx context.Context, db *sql.DB, p Policy, fn func(tx *sql.Tx) error) error {
+	return Do(ctx, p, func(ctx context.Context) error {
+		tx, err := db.BeginTx(ctx, serializable)
+		if err != nil {
+			return err
+		}
+		if err := fn(tx); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	})
+}
+
+// Sqlx runs fn in a serializable sqlx transaction, retrying it with p
+func Sqlx(ctx context.Context, db *sqlx.DB, p Policy, fn func(tx *sqlx.Tx) error) error {
+	return Do(ctx, p, func(ctx context.Context) error {
+		tx, err := db.BeginTxx(ctx, serializable)
+		if err != nil {
+			return err
+		}
+		if err := fn(tx); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	})
+}
+
+// Gorm runs fn in a serializable gorm transaction, retrying it with p
+func Gorm(ctx context.Context, db *gorm.DB, p Policy, fn func(tx *gorm.DB) error) error {
+	return Do(ctx, p, func(ctx context.Context) error {
+		return db.WithContext(ctx).Transaction(fn, serializable)
+	})
+}
+
+// Pgx runs fn in a serializable pgx transaction, retrying it with p. db is usually a *pgxpool.Pool.
+func Pgx(ctx context.Context, db interface {
+	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
+}, p Policy, fn func(tx pgx.Tx) error) error {
+	return Do(ctx, p, func(ctx context.Context) error {
+		return pgx.BeginTxFunc(ctx, db, pgx.TxOptions{IsoLevel: pgx.Serializable}, fn)
+	})
+}
@@ -0,0 +1,112 @@
+package txretry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// sqlStateError is an error from the server with a sqlstate, like the pgconn errors
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "sqlstate " + string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+// fast is a policy with short waits so the tests don't sleep for long
+var fast = Policy{MaxAttempts: 5, Initial: time.Millisecond, Max: 4 * time.Millisecond, Jitter: 0.5}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{sqlStateError("40001"), true}, // serialization failure
+		{sqlStateError("40P01"), true}, // deadlock
+		{fmt.Errorf("commit: %w", sqlStateError("40001")), true},
+		{sqlStateError("23505"), false}, // unique violation
+		{sqlStateError("40002"), false}, // transaction integrity constraint violation
+		{errors.New("connection reset by peer"), false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := Retryable(tt.err); got != tt.want {
+			t.Errorf("Retryable(%v) = %t, want %t", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestDo(t *testing.T) {
+	unique := sqlStateError("23505")
+	plain := errors.New("connection reset by peer")
+	tests := []struct {
+		name string
+		// errs are returned by the attempts in order, the attempts after them succeed
+		errs []error
+		// attempts is how many times Do should call the attempt
+		attempts int
+		want     error
+	}{
+		{"succeeds first time", nil, 1, nil},
+		{"retries serialization failures", []error{sqlStateError("40001"), sqlStateError("40001")}, 3, nil},
+		{"retries deadlocks", []error{sqlStateError("40P01")}, 2, nil},
+		{"stops on other sqlstates", []error{sqlStateError("40001"), unique}, 2, unique},
+		{"stops on other errors", []error{plain}, 1, plain},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			err := Do(context.Background(), fast, func(context.Context) error {
+				attempts++
+				if attempts <= len(tt.errs) {
+					return tt.errs[attempts-1]
+				}
+				return nil
+			})
+			if err != tt.want {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+			if attempts != tt.attempts {
+				t.Errorf("attempted %d times, want %d", attempts, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestDoMaxAttempts(t *testing.T) {
+	attempts, retries := 0, 0
+	p := fast
+	p.OnRetry = func(attempt int, wait time.Duration, err error) {
+		retries++
+		if attempt != retries {
+			t.Errorf("retrying attempt %d, want %d", attempt, retries)
+		}
+		if wait < 0 || wait > p.Max*3/2 {
+			t.Errorf("waiting %s, want at most %s", wait, p.Max*3/2)
+		}
+	}
+	err := Do(context.Background(), p, func(context.Context) error {
+		attempts++
+		return sqlStateError("40001")
+	})
+	if !errors.Is(err, sqlStateError("40001")) {
+		t.Errorf("got %v, want the serialization failure", err)
+	}
+	if attempts != p.MaxAttempts || retries != p.MaxAttempts-1 {
+		t.Errorf("attempted %d times and retried %d, want %d and %d", attempts, retries, p.MaxAttempts, p.MaxAttempts-1)
+	}
+}
+
+func TestDoContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+	err := Do(ctx, Policy{MaxAttempts: 10, Initial: time.Hour, Max: time.Hour}, func(context.Context) error {
+		attempts++
+		cancel()
+		return sqlStateError("40P01")
+	})
+	if !errors.Is(err, sqlStateError("40P01")) || attempts != 1 {
+		t.Errorf("got %v after %d attempts, want the deadlock after 1", err, attempts)
+	}
+}
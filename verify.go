@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"go-orm-test/sqlbdb"
+	"go-orm-test/sqlcdb"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// dbColumn is a column of the migrated database
+type dbColumn struct {
+	name     string
+	udtName  string
+	nullable bool
+}
+
+// generatedModel is a generated struct for a table, with how to find each column's field and which go type the
+// generator produces for a postgres type
+type generatedModel struct {
+	generator string
+	table     string
+	model     any
+	fieldFor  func(f reflect.StructField) (column string, ok bool)
+	goType    func(c dbColumn) string
+}
+
+var generatedModels = []generatedModel{
+	{
+		generator: "sqlboiler",
+		table:     "test.sample_table",
+		model:     sqlbdb.SampleTable{},
+		// the boil tags are what sqlbdb's sampleTableAllColumns is generated from
+		fieldFor: func(f reflect.StructField) (string, bool) {
+			tag := f.Tag.Get("boil")
+			return tag, tag != "" && tag != "-"
+		},
+		goType: sqlboilerGoType,
+	},
+	{
+		generator: "sqlc",
+		table:     "test.sample_table",
+		model:     sqlcdb.TestSampleTable{},
+		fieldFor: func(f reflect.StructField) (string, bool) {
+			return sqlcColumnName(f.Name), true
+		},
+		goType: sqlcGoType,
+	},
+}
+
+// sqlboilerGoType is the type sqlboiler's psql driver generates for a column
+func sqlboilerGoType(c dbColumn) string {
+	types := map[string][2]string{
+		"int2":        {"int16", "null.Int16"},
+		"int4":        {"int", "null.Int"},
+		"int8":        {"int64", "null.Int64"},
+		"float4":      {"float32", "null.Float32"},
+		"float8":      {"float64", "null.Float64"},
+		"numeric":     {"types.Decimal", "types.NullDecimal"},
+		"bool":        {"bool", "null.Bool"},
+		"text":        {"string", "null.String"},
+		"varchar":     {"string", "null.String"},
+		"uuid":        {"string", "null.String"},
+		"json":        {"types.JSON", "null.JSON"},
+		"jsonb":       {"types.JSON", "null.JSON"},
+		"bytea":       {"[]uint8", "null.Bytes"},
+		"date":        {"time.Time", "null.Time"},
+		"timestamp":   {"time.Time", "null.Time"},
+		"timestamptz": {"time.Time", "null.Time"},
+	}
+	return nullableGoType(types, c)
+}
+
+// sqlcGoType is the type sqlc generates for a column with sql_package pgx/v5 and emit_pointers_for_null_types
+func sqlcGoType(c dbColumn) string {
+	types := map[string][2]string{
+		"int2":        {"int16", "*int16"},
+		"int4":        {"int32", "*int32"},
+		"int8":        {"int64", "*int64"},
+		"float4":      {"float32", "*float32"},
+		"float8":      {"float64", "*float64"},
+		"numeric":     {"pgtype.Numeric", "pgtype.Numeric"},
+		"bool":        {"bool", "*bool"},
+		"text":        {"string", "*string"},
+		"varchar":     {"string", "*string"},
+		"uuid":        {"pgtype.UUID", "pgtype.UUID"},
+		"json":        {"[]uint8", "[]uint8"},
+		"jsonb":       {"[]uint8", "[]uint8"},
+		"bytea":       {"[]uint8", "[]uint8"},
+		"date":        {"pgtype.Date", "pgtype.Date"},
+		"timestamp":   {"pgtype.Timestamp", "pgtype.Timestamp"},
+		"timestamptz": {"pgtype.Timestamptz", "pgtype.Timestamptz"},
+	}
+	return nullableGoType(types, c)
+}
+
+func nullableGoType(types map[string][2]string, c dbColumn) string {
+	t, ok := types[c.udtName]
+	if !ok {
+		return ""
+	}
+	if c.nullable {
+		return t[1]
+	}
+	return t[0]
+}
+
+// sqlcColumnName reverses sqlc's field naming, e.g. IntExample is int_example and ID is id
+func sqlcColumnName(field string) string {
+	var b strings.Builder
+	for i, r := range field {
+		upper := r >= 'A' && r <= 'Z'
+		// an upper case letter starts a word, unless it continues an initialism like ID
+		prevUpper := i > 0 && field[i-1] >= 'A' && field[i-1] <= 'Z'
+		if upper && i > 0 && !prevUpper {
+			b.WriteByte('_')
+		}
+		b.WriteRune(r)
+	}
+	return strings.ToLower(b.String())
+}
+
+// runVerify migrates a scratch database and checks the committed sqlbdb and sqlcdb models still match its columns
+func runVerify(ctx context.Context, args []string) int {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	dsn := flags.String("dsn", connectionString, "connection string, the scratch database is created next to it")
+	_ = flags.Parse(args)
+
+	db, drop, err := openMigratedScratchDatabase(ctx, *dsn, "verify")
+	if err != nil {
+		log.Printf("verify: %v", err)
+		return 1
+	}
+	defer drop()
+
+	exitCode := 0
+	for _, m := range generatedModels {
+		columns, err := tableColumns(ctx, db, m.table)
+		if err != nil {
+			log.Printf("verify: %v", err)
+			return 1
+		}
+
+		modelType := reflect.TypeOf(m.model)
+		problems := verifyModel(m, columns)
+		if len(problems) == 0 {
+			fmt.Printf("ok   %s %s matches %s\n", m.generator, modelType, m.table)
+			continue
+		}
+		exitCode = 1
+		fmt.Printf("FAIL %s %s does not match %s, regenerate it:\n", m.generator, modelType, m.table)
+		for _, p := range problems {
+			fmt.Printf("    %s\n", p)
+		}
+	}
+	return exitCode
+}
+
+// tableColumns returns the columns of the schema qualified table in order
+func tableColumns(ctx context.Context, db *sql.DB, table string) ([]dbColumn, error) {
+	schema, name, _ := strings.Cut(table, ".")
+	rows, err := db.QueryContext(ctx, `select column_name, udt_name, is_nullable = 'YES'
+from information_schema.columns
+where table_schema = $1 and table_name = $2
+order by ordinal_position`, schema, name)
+	if err != nil {
+		return nil, err
+	}
+	defer safeClose(rows)
+	columns := make([]dbColumn, 0)
+	for rows.Next() {
+		var c dbColumn
+		if err := rows.Scan(&c.name, &c.udtName, &c.nullable); err != nil {
+			return nil, err
+		}
+		columns = append(columns, c)
+	}
+	return columns, rows.Err()
+}
+
+// verifyModel returns every difference between the model's fields and the table's columns
+func verifyModel(m generatedModel, columns []dbColumn) []string {
+	modelType := reflect.TypeOf(m.model)
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < modelType.NumField(); i++ {
+		f := modelType.Field(i)
+		if column, ok := m.fieldFor(f); ok {
+			fields[column] = f
+		}
+	}
+
+	problems := make([]string, 0)
+	for _, c := range columns {
+		f, ok := fields[c.name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("column %s (%s) has no field", c.name, c.udtName))
+			continue
+		}
+		delete(fields, c.name)
+
+		expected := m.goType(c)
+		if expected == "" {
+			problems = append(problems, fmt.Sprintf("column %s has type %s, which this check does not know the go type for", c.name, c.udtName))
+		} else if actual := f.Type.String(); actual != expected {
+			nullability := "not null"
+			if c.nullable {
+				nullability = "nullable"
+			}
+			problems = append(problems, fmt.Sprintf("field %s is %s, but column %s is %s %s so it should be %s", f.Name, actual, c.name, nullability, c.udtName, expected))
+		}
+	}
+	// whatever is left has no column, in a stable order
+	extra := make([]string, 0, len(fields))
+	for column := range fields {
+		extra = append(extra, column)
+	}
+	sort.Strings(extra)
+	for _, column := range extra {
+		problems = append(problems, fmt.Sprintf("field %s is for column %s, which does not exist", fields[column].Name, column))
+	}
+	return problems
+}